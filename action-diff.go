@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// diffContextLines is the number of unchanged lines shown around each hunk of the unified diff
+const diffContextLines = 3
+
+// Diff runs the merge pipeline in memory and compares the result against the existing output file
+func Diff(request *ExecutionRequestBody) (string, error) {
+	options := request.Options
+	options.DryRun = true
+	compiler := &DockerComposeCompiler{
+		Config: &options,
+		Args:   request.Args,
+	}
+
+	err := compiler.Build()
+	if err != nil {
+		return "", err
+	}
+
+	result, err := compiler.Diff()
+	if err != nil {
+		return "", err
+	}
+
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling diff result: %w", err)
+	}
+
+	return string(resultJSON), nil
+}
+
+// DiffResult describes how the freshly merged compose differs from the existing output file
+type DiffResult struct {
+	// OutputPath is the existing output file compared against
+	OutputPath string `json:"outputPath"`
+	// OutputExists is false when there is no output file yet, everything is then reported as added
+	OutputExists bool `json:"outputExists"`
+	// Stale is true when writing the merged compose would change the output file
+	Stale bool `json:"stale"`
+
+	Services SectionDiff   `json:"services"`
+	Networks SectionDiff   `json:"networks"`
+	Volumes  SectionDiff   `json:"volumes"`
+	Secrets  SectionDiff   `json:"secrets"`
+	Configs  SectionDiff   `json:"configs"`
+	Other    []FieldChange `json:"other"` // changes to any other top level key (version, name, x- extensions, ...)
+
+	// Unified is a unified text diff from the existing output file to the merged compose
+	Unified string `json:"unified"`
+}
+
+// SectionDiff describes the changes to one top level mapping (services, networks, ...)
+type SectionDiff struct {
+	Added   []string                 `json:"added"`
+	Removed []string                 `json:"removed"`
+	Changed map[string][]FieldChange `json:"changed"`
+}
+
+// FieldChange is a single changed value, Path is dotted from the item (e.g. "environment.DEBUG")
+type FieldChange struct {
+	Path string      `json:"path"`
+	Old  interface{} `json:"old"`
+	New  interface{} `json:"new"`
+}
+
+// Diff compares the built Store against the file at OutputPath
+func (dc *DockerComposeCompiler) Diff() (*DiffResult, error) {
+	generated, err := dc.render()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &DiffResult{
+		OutputPath:   dc.OutputPath,
+		OutputExists: true,
+		Other:        []FieldChange{},
+	}
+
+	current, err := os.ReadFile(dc.OutputPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		result.OutputExists = false
+	} else if err != nil {
+		return nil, fmt.Errorf("error reading existing output file: %w", err)
+	}
+
+	existing := &DockerCompose{}
+	if result.OutputExists {
+		// The output was interpolated when it was generated, read it back as written
+		existing, err = dc.readFile(dc.OutputPath, false)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"error reading existing output file:\n\tpath:%s\n\terror:%w",
+				dc.OutputPath,
+				err,
+			)
+		}
+	}
+
+	oldDoc, err := toGeneric(existing)
+	if err != nil {
+		return nil, err
+	}
+	// Compare against what would be written, in its canonical layout, not the unsorted Store
+	newDoc := map[string]interface{}{}
+	err = yaml.Unmarshal(generated, &newDoc)
+	if err != nil {
+		return nil, fmt.Errorf("error reading merged compose: %w", err)
+	}
+
+	sections := map[string]*SectionDiff{
+		"services": &result.Services,
+		"networks": &result.Networks,
+		"volumes":  &result.Volumes,
+		"secrets":  &result.Secrets,
+		"configs":  &result.Configs,
+	}
+	for name, section := range sections {
+		*section = diffSection(asMap(oldDoc[name]), asMap(newDoc[name]))
+	}
+	for _, key := range sortedUnion(oldDoc, newDoc) {
+		if _, isSection := sections[key]; isSection {
+			continue
+		}
+		diffValues(key, oldDoc[key], newDoc[key], &result.Other)
+	}
+
+	result.Stale = !bytes.Equal(current, generated)
+	if result.Stale {
+		result.Unified = unifiedDiff(dc.OutputPath, dc.OutputPath+" (merged)", current, generated)
+	}
+
+	return result, nil
+}
+
+// diffSection compares two top level mappings item by item
+func diffSection(old, new map[string]interface{}) SectionDiff {
+	section := SectionDiff{
+		Added:   []string{},
+		Removed: []string{},
+		Changed: map[string][]FieldChange{},
+	}
+
+	for _, name := range sortedUnion(old, new) {
+		oldItem, inOld := old[name]
+		newItem, inNew := new[name]
+		switch {
+		case !inOld:
+			section.Added = append(section.Added, name)
+		case !inNew:
+			section.Removed = append(section.Removed, name)
+		default:
+			var changes []FieldChange
+			diffValues("", oldItem, newItem, &changes)
+			if len(changes) > 0 {
+				section.Changed[name] = changes
+			}
+		}
+	}
+
+	return section
+}
+
+// diffValues records every difference between old and new, recursing into mappings
+func diffValues(path string, old, new interface{}, changes *[]FieldChange) {
+	oldMap, oldIsMap := old.(map[string]interface{})
+	newMap, newIsMap := new.(map[string]interface{})
+	if oldIsMap && newIsMap {
+		for _, key := range sortedUnion(oldMap, newMap) {
+			keyPath := key
+			if path != "" {
+				keyPath = path + "." + key
+			}
+			diffValues(keyPath, oldMap[key], newMap[key], changes)
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(old, new) {
+		*changes = append(*changes, FieldChange{Path: path, Old: old, New: new})
+	}
+}
+
+// toGeneric converts a compose struct into plain maps and slices, as it would be read back from YAML
+func toGeneric(compose *DockerCompose) (map[string]interface{}, error) {
+	yamlBytes, err := yaml.Marshal(compose)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling DockerCompose data to YAML: %w", err)
+	}
+
+	doc := map[string]interface{}{}
+	err = yaml.Unmarshal(yamlBytes, &doc)
+	if err != nil {
+		return nil, fmt.Errorf("error converting DockerCompose data: %w", err)
+	}
+
+	return doc, nil
+}
+
+func asMap(v interface{}) map[string]interface{} {
+	m, _ := v.(map[string]interface{})
+	return m
+}
+
+// sortedUnion returns the keys of a and b, sorted and without duplicates
+func sortedUnion(a, b map[string]interface{}) []string {
+	return slices.Sorted(maps.Keys(mergeMaps(a, b)))
+}
+
+// unifiedDiff returns a unified diff of a to b, or "" if they are identical
+func unifiedDiff(aName, bName string, a, b []byte) string {
+	aLines := splitLines(a)
+	bLines := splitLines(b)
+	ops := diffLines(aLines, bLines)
+
+	// Find the changed ops and group them into hunks that are close enough to share context
+	var hunks [][2]int
+	for i, op := range ops {
+		if op.kind == ' ' {
+			continue
+		}
+		start := max(i-diffContextLines, 0)
+		end := min(i+diffContextLines+1, len(ops))
+		if n := len(hunks); n > 0 && start <= hunks[n-1][1] {
+			hunks[n-1][1] = end
+		} else {
+			hunks = append(hunks, [2]int{start, end})
+		}
+	}
+	if len(hunks) == 0 {
+		return ""
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", aName, bName)
+	for _, hunk := range hunks {
+		hunkOps := ops[hunk[0]:hunk[1]]
+		aStart, bStart := ops[hunk[0]].aLine, ops[hunk[0]].bLine
+		aCount, bCount := 0, 0
+		for _, op := range hunkOps {
+			if op.kind != '+' {
+				aCount++
+			}
+			if op.kind != '-' {
+				bCount++
+			}
+		}
+
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(aStart, aCount), hunkRange(bStart, bCount))
+		for _, op := range hunkOps {
+			fmt.Fprintf(&out, "%c%s\n", op.kind, op.text)
+		}
+	}
+
+	return out.String()
+}
+
+// diffOp is a single line of an edit script, aLine and bLine are the 0 based positions before it is applied
+type diffOp struct {
+	kind  byte // ' ' unchanged, '-' removed from a, '+' added from b
+	text  string
+	aLine int
+	bLine int
+}
+
+// diffLines computes a line edit script from a to b using the longest common subsequence
+func diffLines(a, b []string) []diffOp {
+	// lcs[i][j] is the length of the LCS of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var ops []diffOp
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, diffOp{kind: ' ', text: a[i], aLine: i, bLine: j})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, diffOp{kind: '-', text: a[i], aLine: i, bLine: j})
+			i++
+		default:
+			ops = append(ops, diffOp{kind: '+', text: b[j], aLine: i, bLine: j})
+			j++
+		}
+	}
+
+	return ops
+}
+
+// hunkRange formats a unified diff range, line numbers are 1 based and an empty range points at the line before
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+func splitLines(b []byte) []string {
+	if len(b) == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+}
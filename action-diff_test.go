@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+	cases := []struct {
+		name string
+		a    string
+		b    string
+		want string // the edit script, one kind and text per line
+	}{
+		{name: "identical", a: "x\ny", b: "x\ny", want: " x\n y"},
+		{name: "both empty", a: "", b: "", want: ""},
+		{name: "all added", a: "", b: "x\ny", want: "+x\n+y"},
+		{name: "all removed", a: "x\ny", b: "", want: "-x\n-y"},
+		{name: "changed line is removed before it is added", a: "x\ny\nz", b: "x\nY\nz", want: " x\n-y\n+Y\n z"},
+		{name: "insertion keeps the common lines", a: "x\nz", b: "x\ny\nz", want: " x\n+y\n z"},
+		{name: "moved line", a: "x\ny\nz", b: "y\nz\nx", want: "-x\n y\n z\n+x"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var script []string
+			for _, op := range diffLines(splitLines([]byte(tc.a)), splitLines([]byte(tc.b))) {
+				script = append(script, fmt.Sprintf("%c%s", op.kind, op.text))
+			}
+			if got := strings.Join(script, "\n"); got != tc.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	cases := []struct {
+		name string
+		a    string
+		b    string
+		want string
+	}{
+		{
+			name: "identical",
+			a:    numberedLines(1, 5),
+			b:    numberedLines(1, 5),
+			want: "",
+		},
+		{
+			name: "single change with context",
+			a:    numberedLines(1, 10),
+			b:    strings.Replace(numberedLines(1, 10), "5\n", "five\n", 1),
+			want: "--- a\n+++ b\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			name: "distant changes give separate hunks",
+			a:    numberedLines(1, 20),
+			b:    strings.Replace(strings.Replace(numberedLines(1, 20), "\n2\n", "\ntwo\n", 1), "18\n", "eighteen\n", 1),
+			want: "--- a\n+++ b\n@@ -1,5 +1,5 @@\n 1\n-2\n+two\n 3\n 4\n 5\n" +
+				"@@ -15,6 +15,6 @@\n 15\n 16\n 17\n-18\n+eighteen\n 19\n 20\n",
+		},
+		{
+			name: "close changes share a hunk",
+			a:    numberedLines(1, 8),
+			b:    "1\n2\nX\n4\n5\n6\n7\nY\n",
+			want: "--- a\n+++ b\n@@ -1,8 +1,8 @@\n 1\n 2\n-3\n+X\n 4\n 5\n 6\n 7\n-8\n+Y\n",
+		},
+		{
+			name: "new file",
+			a:    "",
+			b:    "x\ny\n",
+			want: "--- a\n+++ b\n@@ -0,0 +1,2 @@\n+x\n+y\n",
+		},
+		{
+			name: "single line ranges omit the count",
+			a:    "x\n",
+			b:    "y\n",
+			want: "--- a\n+++ b\n@@ -1 +1 @@\n-x\n+y\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := unifiedDiff("a", "b", []byte(tc.a), []byte(tc.b)); got != tc.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tc.want)
+			}
+		})
+	}
+}
+
+// numberedLines returns the lines from..to, each holding its number
+func numberedLines(from, to int) string {
+	var out strings.Builder
+	for n := from; n <= to; n++ {
+		fmt.Fprintf(&out, "%d\n", n)
+	}
+	return out.String()
+}
@@ -1,10 +1,12 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"gopkg.in/yaml.v3"
@@ -13,6 +15,7 @@ import (
 func Merge(request *ExecutionRequestBody) (string, error) {
 	compiler := &DockerComposeCompiler{
 		Config: &request.Options,
+		Args:   request.Args,
 	}
 
 	err := compiler.Build()
@@ -20,14 +23,102 @@ func Merge(request *ExecutionRequestBody) (string, error) {
 		return "", err
 	}
 
-	return "", nil
+	// A dry run returns the merged compose itself rather than the build summary
+	if request.Options.DryRun {
+		yamlBytes, err := compiler.render()
+		if err != nil {
+			return "", err
+		}
+		return string(yamlBytes), nil
+	}
+
+	result, err := json.Marshal(compiler.Result)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling build result: %w", err)
+	}
+
+	return string(result), nil
 }
 
 type DockerComposeCompiler struct {
 	Config *Options
+	Args   map[string]string
 	Store  *DockerCompose
+	Result *BuildResult
+
+	Variables map[string]string // interpolation variables, loaded by Build when Config.Interpolate is set
+
+	OutputPath string // resolved path of the merged compose file
+	SecretsDir string // resolved directory secret files are rewritten to
+
+	Sources []string // every compose file read by Build (base, projects, overrides and the files they include or extend), in the order read
+}
+
+// BuildResult records what a Build actually did, returned to the odm host as JSON
+type BuildResult struct {
+	// OutputPath is the file the merged compose was written to
+	OutputPath string `json:"outputPath"`
+	// Services lists the names of every service in the merged compose
+	Services []string `json:"services"`
+	// ProjectsSkipped lists projects whose compose file could not be read, in lenient mode
+	ProjectsSkipped []SkippedProject `json:"projectsSkipped"`
+	// Conflicts lists every naming conflict and how it was resolved
+	Conflicts []ConflictResolution `json:"conflicts"`
+	// Overrides lists the override files applied, in the order they were merged
+	Overrides []string `json:"overrides"`
+	// Warnings holds non fatal problems found during the build
+	Warnings []string `json:"warnings"`
+}
+
+// SkippedProject records a project left out of the merge and why
+type SkippedProject struct {
+	Project string `json:"project"`
+	File    string `json:"file"`
+	Reason  string `json:"reason"`
+}
+
+// ConflictResolution records a single conflict between two compose files
+type ConflictResolution struct {
+	Type       string `json:"type"`
+	Name       string `json:"name"`
+	Resolution string `json:"resolution"` // "first" or "second", the item that was kept
+}
+
+func newBuildResult() *BuildResult {
+	return &BuildResult{
+		Services:        []string{},
+		ProjectsSkipped: []SkippedProject{},
+		Conflicts:       []ConflictResolution{},
+		Overrides:       []string{},
+		Warnings:        []string{},
+	}
+}
+
+// addSource records a compose file read by Build, each file once
+func (dc *DockerComposeCompiler) addSource(filePath string) {
+	filePath = filepath.Clean(filePath)
+	if !slices.Contains(dc.Sources, filePath) {
+		dc.Sources = append(dc.Sources, filePath)
+	}
+}
+
+// warn prints a warning and records it on the build result
+func (dc *DockerComposeCompiler) warn(format string, a ...any) {
+	msg := fmt.Sprintf(format, a...)
+	fmt.Println("warning:", msg)
+	if dc.Result != nil {
+		dc.Result.Warnings = append(dc.Result.Warnings, msg)
+	}
 }
 
+// Strictness values for Options.Strictness
+const (
+	// StrictnessStrict turns any unreadable project into a build error
+	StrictnessStrict = "strict"
+	// StrictnessLenient skips unreadable projects and records them on the build result
+	StrictnessLenient = "lenient"
+)
+
 // CombineDockerComposeAdvanced merges two DockerCompose structs with advanced merging options
 type MergeOptions struct {
 	// MergeServices determines if services with same name should be merged (true) or replaced (false)
@@ -42,15 +133,37 @@ type MergeOptions struct {
 // 1. Get and parse base docker-compose.base.yml
 // 2. Get and parse projects docker-compose.yml files
 // 3. Merge base and projects
-// 4. Write new compose to file
+// 4. Apply override files in order
+// 5. Write new compose to file
 
 // Build systems compose file
 func (dc *DockerComposeCompiler) Build() error {
 	if dc.Config.ProjectPath == "" {
 		return fmt.Errorf("project path not set")
 	}
-	if dc.Config.Output == "" {
-		return fmt.Errorf("output path not set")
+	switch dc.Config.Strictness {
+	case "", StrictnessStrict, StrictnessLenient:
+	default:
+		return fmt.Errorf("unknown strictness %q, expected %q or %q", dc.Config.Strictness, StrictnessStrict, StrictnessLenient)
+	}
+	switch dc.Config.PathStyle {
+	case "", PathStyleRelative, PathStyleAbsolute:
+	default:
+		return fmt.Errorf("unknown path style %q, expected %q or %q", dc.Config.PathStyle, PathStyleRelative, PathStyleAbsolute)
+	}
+	dc.Result = newBuildResult()
+	dc.Sources = nil
+
+	err := dc.resolveOutputPaths()
+	if err != nil {
+		return err
+	}
+
+	if dc.Config.Interpolate {
+		err = dc.loadVariables()
+		if err != nil {
+			return err
+		}
 	}
 
 	fmt.Println("Reading base docker-compose file")
@@ -60,10 +173,10 @@ func (dc *DockerComposeCompiler) Build() error {
 		dc.Config.ProjectPath,
 		dc.Config.BasePath,
 	)
-	baseCompose, err := dc.ReadFile(basePath)
+	baseCompose, err := dc.readSourceFile(basePath)
 	if err != nil {
 		return fmt.Errorf(
-			"error reading base yml:\n\tpath:%s\n\terror:%s",
+			"error reading base yml:\n\tpath:%s\n\terror:%w",
 			basePath,
 			err,
 		)
@@ -74,7 +187,7 @@ func (dc *DockerComposeCompiler) Build() error {
 	servicesCompose, err := dc.GetServices()
 	if err != nil {
 		return fmt.Errorf(
-			"error reading service level docker-compose.yml:\n\terror:%s",
+			"error reading service level docker-compose.yml:\n\terror:%w",
 			err,
 		)
 	}
@@ -99,47 +212,167 @@ func (dc *DockerComposeCompiler) Build() error {
 		}
 	}
 
+	fmt.Println("Applying override docker-compose files")
+	combinedStore, err = dc.applyOverrides(combinedStore, mergeOpts)
+	if err != nil {
+		return err
+	}
+
+	if dc.Config.InlineEnvFiles {
+		fmt.Println("Inlining env files")
+		err = dc.inlineEnvFiles(combinedStore)
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Println("Checking service dependencies")
+	err = dc.validateDependencies(combinedStore)
+	if err != nil {
+		return err
+	}
+
 	dc.Store = combinedStore
+	dc.Result.Services = slices.Sorted(maps.Keys(dc.Store.Services))
+
+	if dc.Config.DryRun {
+		fmt.Println("Dry run, not writing file")
+		return nil
+	}
 
 	fmt.Println("Writing file")
-	outputFilePath := fmt.Sprintf(
-		"%s/%s/docker/docker-compose.yml",
-		dc.Config.ProjectPath,
-		dc.Config.Output,
-	)
-	err = dc.writeFile(outputFilePath)
+	err = dc.writeFile(dc.OutputPath)
 	if err != nil {
 		return err
 	}
+	dc.Result.OutputPath = dc.OutputPath
+
+	return nil
+
+}
+
+// applyOverrides merges each file in Config.Overrides on top of compose, in the order given
+func (dc *DockerComposeCompiler) applyOverrides(compose *DockerCompose, opts *MergeOptions) (*DockerCompose, error) {
+	for _, o := range dc.Config.Overrides {
+		overridePath := dc.resolvePath(o)
+		fmt.Println("Reading override docker-compose:", overridePath)
+		overrideCompose, err := dc.readSourceFile(overridePath)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"error reading override yml:\n\tpath:%s\n\terror:%w",
+				overridePath,
+				err,
+			)
+		}
+
+		compose, err = dc.combineDockerCompose(compose, overrideCompose, opts)
+		if err != nil {
+			return nil, err
+		}
+		dc.Result.Overrides = append(dc.Result.Overrides, overridePath)
+	}
+
+	return compose, nil
+}
+
+// validateDependencies checks every depends_on entry refers to a service in compose.
+// Missing dependencies marked `required: false` are only reported as warnings.
+func (dc *DockerComposeCompiler) validateDependencies(compose *DockerCompose) error {
+	var missing []string
+	for _, name := range slices.Sorted(maps.Keys(compose.Services)) {
+		deps := compose.Services[name].DependsOn
+		for _, dep := range slices.Sorted(maps.Keys(deps)) {
+			if _, exists := compose.Services[dep]; exists {
+				continue
+			}
+			if !deps[dep].IsRequired() {
+				dc.warn("service %s depends on %s which is not defined (not required)", name, dep)
+				continue
+			}
+			missing = append(missing, fmt.Sprintf("%s -> %s", name, dep))
+		}
+	}
 
+	if len(missing) > 0 {
+		return fmt.Errorf("services depend on undefined services:\n\t%s", strings.Join(missing, "\n\t"))
+	}
 	return nil
+}
+
+// resolvePath returns p unchanged if absolute, otherwise joined onto the project path
+func (dc *DockerComposeCompiler) resolvePath(p string) string {
+	if filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(dc.Config.ProjectPath, p)
+}
 
+// projectFilePath returns the compose file of a project listed in Config.Projects
+func (dc *DockerComposeCompiler) projectFilePath(project string) string {
+	return dc.composeFileIn(filepath.Join(dc.Config.ProjectPath, dc.Config.ProjectFolder, project))
+}
+
+// selectProjectServices keeps only the services listed for project in Config.ProjectServices.
+// Projects without an entry contribute every service in their file.
+func (dc *DockerComposeCompiler) selectProjectServices(project string, compose *DockerCompose) error {
+	names, listed := dc.Config.ProjectServices[project]
+	if !listed {
+		return nil
+	}
+
+	var missing []string
+	for _, name := range names {
+		if _, exists := compose.Services[name]; !exists {
+			missing = append(missing, name)
+		}
+	}
+	for name := range compose.Services {
+		if !slices.Contains(names, name) {
+			delete(compose.Services, name)
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("services %s not found in project file", strings.Join(missing, ", "))
+	}
+	return nil
 }
 
 func (dc *DockerComposeCompiler) GetServices() (*[]DockerCompose, error) {
+	projects, err := dc.projects()
+	if err != nil {
+		return nil, err
+	}
+
 	var services []DockerCompose
-	for _, s := range dc.Config.Projects {
-		serviceFilePath := filepath.Join(dc.Config.ProjectPath, dc.Config.ProjectFolder, s, "docker-compose.yml")
+	for _, project := range projects {
+		s, serviceFilePath := project.Project, project.File
 		fmt.Println("Reading docker-compose:", serviceFilePath)
-		serviceCompose, err := dc.ReadFile(serviceFilePath)
+		sourcesBefore := len(dc.Sources)
+		serviceCompose, err := dc.readSourceFile(serviceFilePath)
 		if err != nil {
-			fmt.Println("error reading file: ", err)
+			// A skipped project contributes nothing, neither do the files it includes or extends
+			dc.Sources = dc.Sources[:sourcesBefore]
+			if dc.Config.Strictness == StrictnessStrict {
+				return nil, fmt.Errorf("project %s (%s): %w", s, serviceFilePath, err)
+			}
+			dc.warn("skipping project %s, error reading file: %s", s, err)
+			if dc.Result != nil {
+				dc.Result.ProjectsSkipped = append(dc.Result.ProjectsSkipped, SkippedProject{
+					Project: s,
+					File:    serviceFilePath,
+					Reason:  err.Error(),
+				})
+			}
 			continue
 		}
 
-		// Set build context
-		serviceParts := strings.Split(s, "/")
-		serviceName := serviceParts[len(serviceParts)-1]
-		fmt.Println("Service", serviceName)
-		service, exists := serviceCompose.Services[serviceName]
-		fmt.Println("Service exists: ", exists, serviceName)
-		if exists {
-			fmt.Println("Service exists")
-			err = dc.CheckBuildContext(&service, serviceName)
-			if err != nil {
-				fmt.Printf("-%s- Error in build section: %s\n", s, err)
+		err = dc.selectProjectServices(s, serviceCompose)
+		if err != nil {
+			if dc.Config.Strictness == StrictnessStrict {
+				return nil, fmt.Errorf("project %s (%s): %w", s, serviceFilePath, err)
 			}
-			serviceCompose.Services[serviceName] = service
+			dc.warn("project %s: %s", s, err)
 		}
 
 		services = append(services, *serviceCompose)
@@ -150,6 +383,11 @@ func (dc *DockerComposeCompiler) GetServices() (*[]DockerCompose, error) {
 
 // Read and Parse docker-compose.yml file and return as a struct
 func (dc *DockerComposeCompiler) ReadFile(filePath string) (*DockerCompose, error) {
+	return dc.readFile(filePath, dc.Config.Interpolate)
+}
+
+// readFile reads a compose file, substituting variables first if interpolate is set
+func (dc *DockerComposeCompiler) readFile(filePath string, interpolate bool) (*DockerCompose, error) {
 
 	// Read file into a byte array
 	composeFile, err := os.ReadFile(filePath)
@@ -157,26 +395,89 @@ func (dc *DockerComposeCompiler) ReadFile(filePath string) (*DockerCompose, erro
 		return nil, err
 	}
 
-	// Parse the file into {DockerCompose struct}
+	// Parse the file into a node tree first, so errors can be given a position
+	var root yaml.Node
+	err = yaml.Unmarshal(composeFile, &root)
+	if err != nil {
+		return nil, newParseError(filePath, composeFile, nil, err)
+	}
+	if root.Kind == 0 {
+		// Empty file
+		return &DockerCompose{}, nil
+	}
+
+	if interpolate {
+		node, err := dc.interpolateNode(&root)
+		if err != nil {
+			return nil, newNodeError(filePath, composeFile, node, err)
+		}
+	}
+
+	// Decode the tree into {DockerCompose struct}
 	var dockerCompose DockerCompose
-	err = yaml.Unmarshal(composeFile, &dockerCompose)
+	err = root.Decode(&dockerCompose)
 	if err != nil {
-		return nil, err
+		return nil, newParseError(filePath, composeFile, &root, err)
 	}
 
 	return &dockerCompose, nil
 }
 
+// render returns the file that would be written: the provenance header followed by the merged compose
+func (dc *DockerComposeCompiler) render() ([]byte, error) {
+	body, err := dc.renderBody()
+	if err != nil {
+		return nil, err
+	}
+
+	header, err := dc.provenanceHeader(body)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(header), body...), nil
+}
+
+// renderBody marshals the merged compose into YAML, in the canonical layout
+// so identical inputs always give byte identical output
+func (dc *DockerComposeCompiler) renderBody() ([]byte, error) {
+	var root yaml.Node
+	err := root.Encode(dc.Store)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling DockerCompose data to YAML: %w", err)
+	}
+	canonicalize(&root, dc.Config.KeepAnchors)
+
+	if dc.Config.PreserveComments || dc.Config.KeepAnchors {
+		err = dc.decorate(&root)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	yamlBytes, err := yaml.Marshal(&root)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling DockerCompose data to YAML: %w", err)
+	}
+	return yamlBytes, nil
+}
+
 func (dc *DockerComposeCompiler) writeFile(outputFilePath string) error {
 
+	if dc.Config.Check {
+		err := checkUnmodified(outputFilePath)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Marshal the struct into YAML bytes
-	yamlBytes, err := yaml.Marshal(dc.Store)
+	yamlBytes, err := dc.render()
 	if err != nil {
-		return fmt.Errorf("error marshaling DockerCompose data to YAML: %w", err)
+		return err
 	}
 
 	// Write the YAML bytes to the specified output file
-	err = os.WriteFile(outputFilePath, yamlBytes, 0644) // 0644 gives read/write for owner, read-only for others
+	err = writeFileAtomic(outputFilePath, yamlBytes, 0644) // 0644 gives read/write for owner, read-only for others
 	if err != nil {
 		return fmt.Errorf("error writing docker-compose.yml file: %w", err)
 	}
@@ -189,52 +490,14 @@ func (dc *DockerComposeCompiler) writeFile(outputFilePath string) error {
 // DefaultOnConflict is a default implementation for OnConflict that always prefers the second (later) item.
 func (dc *DockerComposeCompiler) DefaultOnConflict(itemType, name string, first, second interface{}) bool {
 	fmt.Printf("Conflict detected for %s '%s'. Preferring the second item.\n", itemType, name)
-	return true // Always return true to use the second (conflicting) item by default
-}
-
-func (dc *DockerComposeCompiler) handleSecrets(a, b *map[string]Secret) (*map[string]Secret, error) {
-
-	result := make(map[string]Secret)
-
-	for name, s := range *a {
-		newSecret := &Secret{
-			File:     s.File,
-			External: s.External,
-			Labels:   s.Labels,
-			Name:     s.Name,
-		}
-		if newSecret.File != "" {
-			filename := filepath.Base(s.File)
-			// Put Build folders config folder as the new location for creds
-			newFilePath := filepath.Join(dc.Config.ProjectPath, dc.Config.Output, "config", filename)
-			fmt.Printf("A --\nFN: %s\nPath: %s\n", filename, newFilePath)
-			newSecret.File = newFilePath
-		}
-		result[name] = *newSecret
-	}
-
-	for name, s := range *b {
-		newSecret := &Secret{
-			File:     s.File,
-			External: s.External,
-			Labels:   s.Labels,
-			Name:     s.Name,
-		}
-		if newSecret.File != "" {
-			filename := filepath.Base(s.File)
-			// Put Build folders config folder as the new location for creds
-			newFilePath := filepath.Join(dc.Config.ProjectPath, dc.Config.Output, "config", filename)
-			fmt.Printf("B --\nFN: %s\nPath: %s\n", filename, newFilePath)
-			newSecret.File = newFilePath
-		}
-		result[name] = *newSecret
-	}
-	for name, s := range result {
-		fmt.Printf("Result =  %s:%s\n", name, s.File)
-
+	if dc.Result != nil {
+		dc.Result.Conflicts = append(dc.Result.Conflicts, ConflictResolution{
+			Type:       itemType,
+			Name:       name,
+			Resolution: "second",
+		})
 	}
-
-	return &result, nil
+	return true // Always return true to use the second (conflicting) item by default
 }
 
 func (dc *DockerComposeCompiler) combineDockerCompose(a, b *DockerCompose, opts *MergeOptions) (*DockerCompose, error) {
@@ -269,10 +532,14 @@ func (dc *DockerComposeCompiler) combineDockerCompose(a, b *DockerCompose, opts
 		}
 	}
 
+	// Merge unmodelled top level keys (x- extensions etc.)
+	result.Extras = mergeExtras(a.Extras, b.Extras)
+
 	// Merge Services
 	maps.Copy(result.Services, a.Services)
 
-	for name, service := range b.Services {
+	for _, name := range slices.Sorted(maps.Keys(b.Services)) {
+		service := b.Services[name]
 		if existing, exists := result.Services[name]; exists {
 			// Handle conflict
 			var useSecond bool
@@ -303,7 +570,8 @@ func (dc *DockerComposeCompiler) combineDockerCompose(a, b *DockerCompose, opts
 
 			maps.Copy(result.Networks, aNetworks)
 
-			for name, network := range bNetworks {
+			for _, name := range slices.Sorted(maps.Keys(bNetworks)) {
+				network := bNetworks[name]
 				if existing, exists := result.Networks[name]; exists && opts.OnConflict != nil {
 					if opts.OnConflict("network", name, existing, network) {
 						result.Networks[name] = network
@@ -318,7 +586,8 @@ func (dc *DockerComposeCompiler) combineDockerCompose(a, b *DockerCompose, opts
 			for name, volume := range aVolumes {
 				result.Volumes[name] = volume
 			}
-			for name, volume := range bVolumes {
+			for _, name := range slices.Sorted(maps.Keys(bVolumes)) {
+				volume := bVolumes[name]
 				if existing, exists := result.Volumes[name]; exists && opts.OnConflict != nil {
 					if opts.OnConflict("volume", name, existing, volume) {
 						result.Volumes[name] = volume
@@ -328,14 +597,19 @@ func (dc *DockerComposeCompiler) combineDockerCompose(a, b *DockerCompose, opts
 				}
 			}
 		case "secrets":
+			// Secret files were already moved to the secrets directory when the source files were read
 			aSecrets := aMap.(map[string]Secret)
 			bSecrets := bMap.(map[string]Secret)
-			newSecrets, err := dc.handleSecrets(&aSecrets, &bSecrets)
-			if err != nil {
-				fmt.Println(err)
-
-			} else {
-				result.Secrets = *newSecrets
+			maps.Copy(result.Secrets, aSecrets)
+			for _, name := range slices.Sorted(maps.Keys(bSecrets)) {
+				secret := bSecrets[name]
+				if existing, exists := result.Secrets[name]; exists && opts.OnConflict != nil {
+					if opts.OnConflict("secret", name, existing, secret) {
+						result.Secrets[name] = secret
+					}
+				} else if !exists || !opts.PreferFirst {
+					result.Secrets[name] = secret
+				}
 			}
 		case "configs":
 			aConfigs := aMap.(map[string]Config)
@@ -343,7 +617,8 @@ func (dc *DockerComposeCompiler) combineDockerCompose(a, b *DockerCompose, opts
 			for name, config := range aConfigs {
 				result.Configs[name] = config
 			}
-			for name, config := range bConfigs {
+			for _, name := range slices.Sorted(maps.Keys(bConfigs)) {
+				config := bConfigs[name]
 				if existing, exists := result.Configs[name]; exists && opts.OnConflict != nil {
 					if opts.OnConflict("config", name, existing, config) {
 						result.Configs[name] = config
@@ -362,81 +637,3 @@ func (dc *DockerComposeCompiler) combineDockerCompose(a, b *DockerCompose, opts
 
 	return result, nil
 }
-
-// mergeServices combines two services, with the second taking precedence for conflicting fields
-func (dc *DockerComposeCompiler) mergeServices(a, b Service) Service {
-	result := a // Start with first service
-
-	// Override with non-zero values from second service
-	if b.Image != "" {
-		result.Image = b.Image
-	}
-	if b.Build != nil {
-		result.Build = b.Build
-	}
-	if b.ContainerName != "" {
-		result.ContainerName = b.ContainerName
-	}
-	if b.Command != nil {
-		result.Command = b.Command
-	}
-	if b.Entrypoint != nil {
-		result.Entrypoint = b.Entrypoint
-	}
-
-	// Merge maps
-	if len(b.Environment) > 0 {
-		if result.Environment == nil {
-			result.Environment = make(map[string]string)
-		}
-		for k, v := range b.Environment {
-			result.Environment[k] = v
-		}
-	}
-
-	if len(b.Labels) > 0 {
-		if result.Labels == nil {
-			result.Labels = make(map[string]string)
-		}
-		for k, v := range b.Labels {
-			result.Labels[k] = v
-		}
-	}
-
-	// Merge slices (append)
-	if len(b.Ports) > 0 {
-		result.Ports = append(result.Ports, b.Ports...)
-	}
-	if len(b.Volumes) > 0 {
-		result.Volumes = append(result.Volumes, b.Volumes...)
-	}
-
-	// Handle service dependsOn
-	result.DependsOn = b.DependsOn
-
-	// Override other fields
-	if b.Restart != "" {
-		result.Restart = b.Restart
-	}
-	if b.User != "" {
-		result.User = b.User
-	}
-	if b.WorkingDir != "" {
-		result.WorkingDir = b.WorkingDir
-	}
-
-	return result
-}
-
-// Check if a service has a build context and set it correctly
-func (dc *DockerComposeCompiler) CheckBuildContext(service *Service, serviceName string) error {
-	if service.Build == nil {
-		// Build field is nil, so no build context exists
-		return fmt.Errorf("Build field not found")
-	}
-
-	// Set Build context to work correctly
-	service.Build.Context = fmt.Sprintf("%s/%s/%s", dc.Config.ProjectPath, dc.Config.ProjectFolder, serviceName)
-
-	return nil
-}
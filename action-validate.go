@@ -0,0 +1,509 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"gopkg.in/yaml.v3"
+)
+
+// composeSpecSchema is the compose-spec JSON schema, taken from github.com/compose-spec/compose-go (Apache 2.0)
+//
+//go:embed schema/compose-spec.json
+var composeSpecSchema []byte
+
+// Validate checks the input compose files and the merged result without writing anything
+func Validate(request *ExecutionRequestBody) (string, error) {
+	options := request.Options
+	options.DryRun = true
+	compiler := &DockerComposeCompiler{
+		Config: &options,
+		Args:   request.Args,
+	}
+
+	result, err := compiler.Validate()
+	if err != nil {
+		return "", err
+	}
+
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling validation result: %w", err)
+	}
+
+	return string(resultJSON), nil
+}
+
+// ValidationResult lists every problem found by the validate action
+type ValidationResult struct {
+	Valid  bool              `json:"valid"`
+	Errors []ValidationError `json:"errors"`
+}
+
+// ValidationError is a single problem found in a compose file
+type ValidationError struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Path    string `json:"path"` // dotted path of the offending value, e.g. services.api.ports.0
+	Message string `json:"message"`
+}
+
+// Validate checks the base, project and override files against the compose-spec schema,
+// then builds the merged compose and checks it against the schema and the semantic rules
+func (dc *DockerComposeCompiler) Validate() (*ValidationResult, error) {
+	schema, err := compileComposeSchema()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ValidationResult{
+		Errors: []ValidationError{},
+	}
+
+	if dc.Config.Interpolate {
+		err = dc.loadVariables()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	files, err := dc.inputFiles()
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			result.Errors = append(result.Errors, ValidationError{File: file, Message: err.Error()})
+			continue
+		}
+		result.Errors = append(result.Errors, dc.validateDocument(schema, file, data, dc.Config.Interpolate)...)
+	}
+
+	err = dc.Build()
+	if err != nil {
+		result.Errors = append(result.Errors, ValidationError{
+			File:    "(merged)",
+			Message: err.Error(),
+		})
+	} else {
+		rendered, err := dc.render()
+		if err != nil {
+			return nil, err
+		}
+		// Nothing is written, positions in the merged compose refer to the output as a merge would write it
+		mergedFile := dc.OutputPath + " (dry-run output, not written)"
+		result.Errors = append(result.Errors, dc.validateDocument(schema, mergedFile, rendered, false)...)
+
+		var root yaml.Node
+		err = yaml.Unmarshal(rendered, &root)
+		if err != nil {
+			return nil, fmt.Errorf("error reading merged compose: %w", err)
+		}
+
+		// Semantic problems are reported in the source file that last defined the offending entry,
+		// later files win, falling back to the dry-run output when no source file has it as written
+		sources := dc.readSourceDocuments()
+		for _, problem := range checkSemantics(dc.Store) {
+			file := mergedFile
+			node, path, _ := problem.locate(&root)
+			for i := len(sources) - 1; i >= 0; i-- {
+				if sourceNode, sourcePath, found := problem.locate(sources[i].root); found {
+					file, node, path = sources[i].file, sourceNode, sourcePath
+					break
+				}
+			}
+			result.Errors = append(result.Errors, ValidationError{
+				File:    file,
+				Line:    node.Line,
+				Column:  node.Column,
+				Path:    strings.Join(path, "."),
+				Message: problem.message,
+			})
+		}
+	}
+
+	result.Valid = len(result.Errors) == 0
+	return result, nil
+}
+
+// sourceDocument is a source compose file parsed as a node tree, to report positions in
+type sourceDocument struct {
+	file string
+	root *yaml.Node
+}
+
+// readSourceDocuments parses every file read by Build in merge order, substituting variables if enabled.
+// Files that cannot be read or parsed are skipped, their errors were already reported.
+func (dc *DockerComposeCompiler) readSourceDocuments() []sourceDocument {
+	var documents []sourceDocument
+	for _, file := range dc.Sources {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		var root yaml.Node
+		if yaml.Unmarshal(data, &root) != nil {
+			continue
+		}
+		if dc.Config.Interpolate {
+			if _, err := dc.interpolateNode(&root); err != nil {
+				continue
+			}
+		}
+		documents = append(documents, sourceDocument{file: file, root: &root})
+	}
+	return documents
+}
+
+// inputFiles lists the base, project and override compose files in merge order
+func (dc *DockerComposeCompiler) inputFiles() ([]string, error) {
+	projects, err := dc.projects()
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{dc.resolvePath(dc.Config.BasePath)}
+	for _, p := range projects {
+		files = append(files, p.File)
+	}
+	for _, o := range dc.Config.Overrides {
+		files = append(files, dc.resolvePath(o))
+	}
+	return files, nil
+}
+
+func compileComposeSchema() (*jsonschema.Schema, error) {
+	compiler := jsonschema.NewCompiler()
+	err := compiler.AddResource("compose-spec.json", bytes.NewReader(composeSpecSchema))
+	if err != nil {
+		return nil, fmt.Errorf("error loading compose-spec schema: %w", err)
+	}
+	schema, err := compiler.Compile("compose-spec.json")
+	if err != nil {
+		return nil, fmt.Errorf("error compiling compose-spec schema: %w", err)
+	}
+	return schema, nil
+}
+
+// validateDocument checks a single YAML document against the compose-spec schema,
+// substituting variables first if interpolate is set
+func (dc *DockerComposeCompiler) validateDocument(schema *jsonschema.Schema, file string, data []byte, interpolate bool) []ValidationError {
+	var root yaml.Node
+	err := yaml.Unmarshal(data, &root)
+	if err != nil {
+		parseErr := newParseError(file, data, nil, err)
+		return []ValidationError{{File: file, Line: parseErr.Line, Column: parseErr.Column, Message: err.Error()}}
+	}
+	if interpolate {
+		node, err := dc.interpolateNode(&root)
+		if err != nil {
+			return []ValidationError{{File: file, Line: node.Line, Column: node.Column, Message: err.Error()}}
+		}
+	}
+
+	// The schema validator expects values exactly as encoding/json would produce them
+	var doc interface{}
+	err = root.Decode(&doc)
+	if err == nil {
+		var jsonBytes []byte
+		jsonBytes, err = json.Marshal(doc)
+		if err == nil {
+			decoder := json.NewDecoder(bytes.NewReader(jsonBytes))
+			decoder.UseNumber()
+			err = decoder.Decode(&doc)
+		}
+	}
+	if err != nil {
+		return []ValidationError{{File: file, Message: fmt.Sprintf("error converting compose file for validation: %s", err)}}
+	}
+
+	err = schema.Validate(doc)
+	var validationErr *jsonschema.ValidationError
+	if !errors.As(err, &validationErr) {
+		if err != nil {
+			return []ValidationError{{File: file, Message: err.Error()}}
+		}
+		return nil
+	}
+
+	var problems []ValidationError
+	for _, leaf := range schemaErrorLeaves(validationErr) {
+		path := splitPointer(leaf.InstanceLocation)
+		node := nodeAt(&root, path)
+		problems = append(problems, ValidationError{
+			File:    file,
+			Line:    node.Line,
+			Column:  node.Column,
+			Path:    strings.Join(path, "."),
+			Message: leaf.Message,
+		})
+	}
+	return problems
+}
+
+// schemaErrorLeaves returns the innermost causes of a schema validation error, which carry the useful messages
+func schemaErrorLeaves(err *jsonschema.ValidationError) []*jsonschema.ValidationError {
+	if len(err.Causes) == 0 {
+		return []*jsonschema.ValidationError{err}
+	}
+	var leaves []*jsonschema.ValidationError
+	for _, cause := range err.Causes {
+		leaves = append(leaves, schemaErrorLeaves(cause)...)
+	}
+	return leaves
+}
+
+// splitPointer splits a JSON pointer ("/services/api") into its unescaped segments
+func splitPointer(pointer string) []string {
+	if pointer == "" || pointer == "/" {
+		return nil
+	}
+	segments := strings.Split(strings.TrimPrefix(pointer, "/"), "/")
+	for i, s := range segments {
+		segments[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(s)
+	}
+	return segments
+}
+
+// nodeAt follows a path of mapping keys and sequence indexes from root,
+// returning the deepest node found so callers always get a position
+func nodeAt(root *yaml.Node, path []string) *yaml.Node {
+	node, _ := walkPath(root, path)
+	return node
+}
+
+// walkPath follows path from root as far as it exists, returning the node reached and how many segments were followed
+func walkPath(root *yaml.Node, path []string) (*yaml.Node, int) {
+	node := root
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+
+	for depth, segment := range path {
+		if node.Kind == yaml.AliasNode {
+			node = node.Alias
+		}
+
+		var next *yaml.Node
+		switch node.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == segment {
+					next = node.Content[i+1]
+					break
+				}
+			}
+		case yaml.SequenceNode:
+			if i, err := strconv.Atoi(segment); err == nil && i >= 0 && i < len(node.Content) {
+				next = node.Content[i]
+			}
+		}
+		if next == nil {
+			return node, depth
+		}
+		node = next
+	}
+
+	return node, len(path)
+}
+
+// semanticProblem is a rule violation in the merged compose, path is where it should be reported.
+// When the problem is a single list entry, path is the list and entry picks the offending entry out of it:
+// lists are sorted when written, so an index into the merged store does not point at the same entry.
+type semanticProblem struct {
+	path    []string
+	entry   func(*yaml.Node) bool
+	message string
+}
+
+// locate finds the node a problem should be reported at in root and its path, the index of the entry included.
+// found is false when root does not have the path or entry, the node is then the closest one to it.
+func (p semanticProblem) locate(root *yaml.Node) (node *yaml.Node, path []string, found bool) {
+	node, depth := walkPath(root, p.path)
+	if depth < len(p.path) {
+		return node, p.path, false
+	}
+	if p.entry == nil {
+		return node, p.path, true
+	}
+
+	if node.Kind == yaml.SequenceNode {
+		for i, item := range node.Content {
+			if p.entry(item) {
+				return item, append(slices.Clone(p.path), strconv.Itoa(i)), true
+			}
+		}
+	}
+	return node, p.path, false
+}
+
+// entryWhere matches list entries that decode to a T satisfying match
+func entryWhere[T any](match func(T) bool) func(*yaml.Node) bool {
+	return func(node *yaml.Node) bool {
+		var value T
+		return node.Decode(&value) == nil && match(value)
+	}
+}
+
+// checkSemantics applies the rules the schema cannot express: references to undefined top level
+// networks, volumes, secrets and configs, dependency cycles, duplicate container names and
+// published port collisions
+func checkSemantics(compose *DockerCompose) []semanticProblem {
+	var problems []semanticProblem
+	add := func(message string, path ...string) {
+		problems = append(problems, semanticProblem{path: path, message: message})
+	}
+	addEntry := func(message string, entry func(*yaml.Node) bool, path ...string) {
+		problems = append(problems, semanticProblem{path: path, entry: entry, message: message})
+	}
+
+	containerNames := map[string]string{}
+	publishedPorts := map[string]string{}
+
+	for _, name := range slices.Sorted(maps.Keys(compose.Services)) {
+		service := compose.Services[name]
+
+		for _, network := range slices.Sorted(maps.Keys(networksToMap(service.Networks))) {
+			if _, exists := compose.Networks[network]; !exists && network != "default" {
+				add(fmt.Sprintf("service %s uses undefined network %s", name, network), "services", name, "networks")
+			}
+		}
+
+		for _, volume := range service.Volumes {
+			if volume.Type != "volume" || volume.Source == "" {
+				continue
+			}
+			if _, exists := compose.Volumes[volume.Source]; !exists {
+				addEntry(fmt.Sprintf("service %s uses undefined volume %s", name, volume.Source),
+					entryWhere(func(v ServiceVolumeConfig) bool { return v.Key() == volume.Key() }),
+					"services", name, "volumes")
+			}
+		}
+
+		for _, secret := range service.Secrets {
+			if _, exists := compose.Secrets[secret.Source]; !exists {
+				addEntry(fmt.Sprintf("service %s uses undefined secret %s", name, secret.Source),
+					entryWhere(func(s ServiceFileReferenceConfig) bool { return s.Key("/run/secrets") == secret.Key("/run/secrets") }),
+					"services", name, "secrets")
+			}
+		}
+
+		for _, config := range service.Configs {
+			if _, exists := compose.Configs[config.Source]; !exists {
+				addEntry(fmt.Sprintf("service %s uses undefined config %s", name, config.Source),
+					entryWhere(func(c ServiceFileReferenceConfig) bool { return c.Key("/") == config.Key("/") }),
+					"services", name, "configs")
+			}
+		}
+
+		if service.ContainerName != "" {
+			if other, exists := containerNames[service.ContainerName]; exists {
+				add(fmt.Sprintf("services %s and %s use the same container name %s", other, name, service.ContainerName), "services", name, "container_name")
+			} else {
+				containerNames[service.ContainerName] = name
+			}
+		}
+
+		for _, port := range service.Ports {
+			for _, key := range publishedPortKeys(port) {
+				if other, exists := publishedPorts[key]; exists {
+					addEntry(fmt.Sprintf("services %s and %s both publish %s", other, name, key),
+						entryWhere(func(p ServicePortConfig) bool {
+							return p.Key() == port.Key() && slices.Contains(publishedPortKeys(p), key)
+						}),
+						"services", name, "ports")
+					continue
+				}
+				publishedPorts[key] = name
+			}
+		}
+	}
+
+	for _, cycle := range dependencyCycles(compose) {
+		add(fmt.Sprintf("dependency cycle: %s", strings.Join(cycle, " -> ")), "services", cycle[0], "depends_on")
+	}
+
+	return problems
+}
+
+// publishedPortKeys returns a "host_ip:published/protocol" key for each host port a port entry publishes
+func publishedPortKeys(port ServicePortConfig) []string {
+	if port.Published == "" {
+		return nil
+	}
+
+	protocol := strings.ToLower(port.Protocol)
+	if protocol == "" {
+		protocol = "tcp"
+	}
+
+	published := []string{string(port.Published)}
+	if from, to, isRange := strings.Cut(string(port.Published), "-"); isRange {
+		start, errStart := strconv.Atoi(from)
+		end, errEnd := strconv.Atoi(to)
+		if errStart == nil && errEnd == nil && start <= end {
+			published = published[:0]
+			for p := start; p <= end; p++ {
+				published = append(published, strconv.Itoa(p))
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(published))
+	for _, p := range published {
+		keys = append(keys, fmt.Sprintf("%s:%s/%s", port.HostIP, p, protocol))
+	}
+	return keys
+}
+
+// dependencyCycles returns each depends_on cycle once, as the list of services ending where it started
+func dependencyCycles(compose *DockerCompose) [][]string {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := map[string]int{}
+	var stack []string
+	var cycles [][]string
+
+	var visit func(name string)
+	visit = func(name string) {
+		state[name] = visiting
+		stack = append(stack, name)
+		for _, dep := range slices.Sorted(maps.Keys(compose.Services[name].DependsOn)) {
+			if _, exists := compose.Services[dep]; !exists {
+				continue
+			}
+			switch state[dep] {
+			case unvisited:
+				visit(dep)
+			case visiting:
+				start := slices.Index(stack, dep)
+				cycle := slices.Clone(stack[start:])
+				cycles = append(cycles, append(cycle, dep))
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[name] = done
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(compose.Services)) {
+		if state[name] == unvisited {
+			visit(name)
+		}
+	}
+
+	return cycles
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// defaultComposeFileNames are the file names looked for in a project directory, in order of preference
+var defaultComposeFileNames = []string{"docker-compose.yml"}
+
+// projectFile is a project to merge and the compose file it is read from
+type projectFile struct {
+	Project string // name used in logs, results and Options.ProjectServices
+	File    string
+}
+
+// projects lists the projects to merge: those in Config.Projects in the order given, followed by
+// those discovered through Config.ProjectGlobs sorted by name, so the merge order is reproducible
+func (dc *DockerComposeCompiler) projects() ([]projectFile, error) {
+	var projects []projectFile
+	seen := map[string]bool{}
+
+	for _, project := range dc.Config.Projects {
+		file := dc.projectFilePath(project)
+		if !seen[file] {
+			seen[file] = true
+			projects = append(projects, projectFile{Project: project, File: file})
+		}
+	}
+
+	discovered, err := dc.discoverProjects()
+	if err != nil {
+		return nil, err
+	}
+	for _, project := range discovered {
+		if !seen[project.File] {
+			seen[project.File] = true
+			projects = append(projects, project)
+		}
+	}
+
+	return projects, nil
+}
+
+// discoverProjects expands Config.ProjectGlobs (relative to ProjectPath). A pattern may match compose files
+// or project directories, the compose file of a directory is found through Config.ComposeFileNames.
+// Projects whose directory (relative to ProjectPath) matches one of Config.ProjectExcludes are left out.
+func (dc *DockerComposeCompiler) discoverProjects() ([]projectFile, error) {
+	var projects []projectFile
+
+	for _, pattern := range dc.Config.ProjectGlobs {
+		matches, err := filepath.Glob(dc.resolvePath(pattern))
+		if err != nil {
+			return nil, fmt.Errorf("invalid project glob %q: %w", pattern, err)
+		}
+
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil {
+				return nil, fmt.Errorf("error discovering projects:\n\tpath:%s\n\terror:%w", match, err)
+			}
+
+			dir, file := filepath.Dir(match), match
+			if info.IsDir() {
+				dir = match
+				file = dc.composeFileIn(dir)
+				if _, err := os.Stat(file); err != nil {
+					// Not a project, or one without a compose file
+					continue
+				}
+			}
+
+			excluded, err := dc.isExcluded(dir)
+			if err != nil {
+				return nil, err
+			}
+			if excluded {
+				fmt.Println("Excluding project:", dir)
+				continue
+			}
+
+			projects = append(projects, projectFile{Project: dc.projectName(dir), File: file})
+		}
+	}
+
+	slices.SortFunc(projects, func(a, b projectFile) int {
+		return strings.Compare(a.Project, b.Project)
+	})
+	return projects, nil
+}
+
+// composeFileIn returns the first of Config.ComposeFileNames present in dir, or the first name if none are
+func (dc *DockerComposeCompiler) composeFileIn(dir string) string {
+	names := dc.Config.ComposeFileNames
+	if len(names) == 0 {
+		names = defaultComposeFileNames
+	}
+
+	for _, name := range names {
+		file := filepath.Join(dir, name)
+		if _, err := os.Stat(file); !errors.Is(err, fs.ErrNotExist) {
+			return file
+		}
+	}
+	return filepath.Join(dir, names[0])
+}
+
+// isExcluded reports whether the project directory matches any of Config.ProjectExcludes
+func (dc *DockerComposeCompiler) isExcluded(dir string) (bool, error) {
+	rel, err := filepath.Rel(dc.Config.ProjectPath, dir)
+	if err != nil {
+		return false, err
+	}
+
+	for _, pattern := range dc.Config.ProjectExcludes {
+		matched, err := filepath.Match(pattern, rel)
+		if err != nil {
+			return false, fmt.Errorf("invalid project exclude %q: %w", pattern, err)
+		}
+		if matched {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// projectName names a discovered project like those in Config.Projects, by its directory relative
+// to the project folder, or relative to ProjectPath when it is outside the project folder
+func (dc *DockerComposeCompiler) projectName(dir string) string {
+	rel, err := filepath.Rel(filepath.Join(dc.Config.ProjectPath, dc.Config.ProjectFolder), dir)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		rel, err = filepath.Rel(dc.Config.ProjectPath, dir)
+		if err != nil {
+			return dir
+		}
+	}
+	return filepath.ToSlash(rel)
+}
@@ -7,68 +7,81 @@ import (
 // DockerCompose represents the root structure of a docker-compose.yml file
 type DockerCompose struct {
 	Version  string             `yaml:"version,omitempty"`
+	Include  []IncludeConfig    `yaml:"include,omitempty"` // resolved when the file is read
 	Services map[string]Service `yaml:"services,omitempty"`
 	Networks map[string]Network `yaml:"networks,omitempty"`
 	Volumes  map[string]Volume  `yaml:"volumes,omitempty"`
 	Secrets  map[string]Secret  `yaml:"secrets,omitempty"`
 	Configs  map[string]Config  `yaml:"configs,omitempty"`
+
+	Extras map[string]interface{} `yaml:",inline"` // top level keys not modelled above, including x- extensions
+}
+
+// IncludeConfig is a single entry of the top level include list, `- path` is read as just the path
+type IncludeConfig struct {
+	Path             StringList `yaml:"path"`                        // files merged into one included model, later files overriding
+	ProjectDirectory string     `yaml:"project_directory,omitempty"` // directory relative paths in the files resolve against (default: directory of the first file)
+	EnvFile          StringList `yaml:"env_file,omitempty"`          // env files providing variables for the included files
 }
 
 // Service represents a service definition in docker-compose
 type Service struct {
-	Image           string                 `yaml:"image,omitempty"`
-	Environment     map[string]string      `yaml:"environment,omitempty"` // Changed to []string
-	Build           *BuildConfig           `yaml:"build,omitempty"`
-	ContainerName   string                 `yaml:"container_name,omitempty"`
-	Command         interface{}            `yaml:"command,omitempty"`    // string or []string
-	Entrypoint      interface{}            `yaml:"entrypoint,omitempty"` // string or []string
-	EnvFile         interface{}            `yaml:"env_file,omitempty"`   // string or []string
-	Ports           []string               `yaml:"ports,omitempty"`
-	Expose          []string               `yaml:"expose,omitempty"`
-	Volumes         []string               `yaml:"volumes,omitempty"`
-	VolumesFrom     []string               `yaml:"volumes_from,omitempty"`
-	Networks        interface{}            `yaml:"networks,omitempty"`   // []string or map[string]NetworkConfig
-	DependsOn       interface{}            `yaml:"depends_on,omitempty"` // []string or map[string]DependsOnConfig
-	Links           []string               `yaml:"links,omitempty"`
-	ExternalLinks   []string               `yaml:"external_links,omitempty"`
-	Restart         string                 `yaml:"restart,omitempty"`
-	User            string                 `yaml:"user,omitempty"`
-	WorkingDir      string                 `yaml:"working_dir,omitempty"`
-	Hostname        string                 `yaml:"hostname,omitempty"`
-	DomainName      string                 `yaml:"domainname,omitempty"`
-	MacAddress      string                 `yaml:"mac_address,omitempty"`
-	Privileged      bool                   `yaml:"privileged,omitempty"`
-	ReadOnly        bool                   `yaml:"read_only,omitempty"`
-	StdinOpen       bool                   `yaml:"stdin_open,omitempty"`
-	Tty             bool                   `yaml:"tty,omitempty"`
-	CPU             float64                `yaml:"cpu_shares,omitempty"`
-	CPUs            string                 `yaml:"cpus,omitempty"`
-	CPUSet          string                 `yaml:"cpuset,omitempty"`
-	Memory          string                 `yaml:"mem_limit,omitempty"`
-	MemSwap         string                 `yaml:"memswap_limit,omitempty"`
-	ShmSize         string                 `yaml:"shm_size,omitempty"`
-	PidMode         string                 `yaml:"pid,omitempty"`
-	IPC             string                 `yaml:"ipc,omitempty"`
-	SecurityOpt     []string               `yaml:"security_opt,omitempty"`
-	StopSignal      string                 `yaml:"stop_signal,omitempty"`
-	StopGracePeriod *time.Duration         `yaml:"stop_grace_period,omitempty"`
-	Ulimits         map[string]interface{} `yaml:"ulimits,omitempty"`
-	Devices         []string               `yaml:"devices,omitempty"`
-	Labels          map[string]string      `yaml:"labels,omitempty"`
-	LogDriver       string                 `yaml:"log_driver,omitempty"`
-	LogOpt          map[string]string      `yaml:"log_opt,omitempty"`
-	ExtraHosts      []string               `yaml:"extra_hosts,omitempty"`
-	DNS             interface{}            `yaml:"dns,omitempty"` // string or []string
-	DNSSearch       []string               `yaml:"dns_search,omitempty"`
-	DNSOpt          []string               `yaml:"dns_opt,omitempty"`
-	TmpFS           interface{}            `yaml:"tmpfs,omitempty"` // string or []string
-	Secrets         []string               `yaml:"secrets,omitempty"`
-	Configs         []string               `yaml:"configs,omitempty"`
-	Deploy          *DeployConfig          `yaml:"deploy,omitempty"`
-	HealthCheck     *HealthCheckConfig     `yaml:"healthcheck,omitempty"`
-}
-
-// BuildConfig represents build configuration
+	Image           string                       `yaml:"image,omitempty"`
+	Environment     Environment                  `yaml:"environment,omitempty"` // []string or map[string]string
+	Build           *BuildConfig                 `yaml:"build,omitempty"`
+	Extends         *ExtendsConfig               `yaml:"extends,omitempty"` // string or ExtendsConfig, resolved when the file is read
+	ContainerName   string                       `yaml:"container_name,omitempty"`
+	Command         interface{}                  `yaml:"command,omitempty"`    // string or []string
+	Entrypoint      interface{}                  `yaml:"entrypoint,omitempty"` // string or []string
+	EnvFile         EnvFiles                     `yaml:"env_file,omitempty"`   // string, []string or []EnvFileConfig
+	Ports           []ServicePortConfig          `yaml:"ports,omitempty"`
+	Expose          []string                     `yaml:"expose,omitempty"`
+	Volumes         []ServiceVolumeConfig        `yaml:"volumes,omitempty"`
+	VolumesFrom     []string                     `yaml:"volumes_from,omitempty"`
+	Networks        interface{}                  `yaml:"networks,omitempty"`   // []string or map[string]NetworkConfig
+	DependsOn       DependsOn                    `yaml:"depends_on,omitempty"` // []string or map[string]DependsOnConfig
+	Links           []string                     `yaml:"links,omitempty"`
+	ExternalLinks   []string                     `yaml:"external_links,omitempty"`
+	Restart         string                       `yaml:"restart,omitempty"`
+	User            string                       `yaml:"user,omitempty"`
+	WorkingDir      string                       `yaml:"working_dir,omitempty"`
+	Hostname        string                       `yaml:"hostname,omitempty"`
+	DomainName      string                       `yaml:"domainname,omitempty"`
+	MacAddress      string                       `yaml:"mac_address,omitempty"`
+	Privileged      *bool                        `yaml:"privileged,omitempty"`
+	ReadOnly        *bool                        `yaml:"read_only,omitempty"`
+	StdinOpen       *bool                        `yaml:"stdin_open,omitempty"`
+	Tty             *bool                        `yaml:"tty,omitempty"`
+	CPU             float64                      `yaml:"cpu_shares,omitempty"`
+	CPUs            string                       `yaml:"cpus,omitempty"`
+	CPUSet          string                       `yaml:"cpuset,omitempty"`
+	Memory          string                       `yaml:"mem_limit,omitempty"`
+	MemSwap         string                       `yaml:"memswap_limit,omitempty"`
+	ShmSize         string                       `yaml:"shm_size,omitempty"`
+	PidMode         string                       `yaml:"pid,omitempty"`
+	IPC             string                       `yaml:"ipc,omitempty"`
+	SecurityOpt     []string                     `yaml:"security_opt,omitempty"`
+	StopSignal      string                       `yaml:"stop_signal,omitempty"`
+	StopGracePeriod *time.Duration               `yaml:"stop_grace_period,omitempty"`
+	Ulimits         map[string]interface{}       `yaml:"ulimits,omitempty"`
+	Devices         []string                     `yaml:"devices,omitempty"`
+	Labels          map[string]string            `yaml:"labels,omitempty"`
+	LogDriver       string                       `yaml:"log_driver,omitempty"`
+	LogOpt          map[string]string            `yaml:"log_opt,omitempty"`
+	ExtraHosts      []string                     `yaml:"extra_hosts,omitempty"`
+	DNS             interface{}                  `yaml:"dns,omitempty"` // string or []string
+	DNSSearch       []string                     `yaml:"dns_search,omitempty"`
+	DNSOpt          []string                     `yaml:"dns_opt,omitempty"`
+	TmpFS           interface{}                  `yaml:"tmpfs,omitempty"` // string or []string
+	Secrets         []ServiceFileReferenceConfig `yaml:"secrets,omitempty"`
+	Configs         []ServiceFileReferenceConfig `yaml:"configs,omitempty"`
+	Deploy          *DeployConfig                `yaml:"deploy,omitempty"`
+	HealthCheck     *HealthCheckConfig           `yaml:"healthcheck,omitempty"`
+
+	Extras map[string]interface{} `yaml:",inline"` // service keys not modelled above (profiles, platform, cap_add, x- extensions, ...)
+}
+
+// BuildConfig represents build configuration, `build: ./dir` is read as just the context
 type BuildConfig struct {
 	Context    string            `yaml:"context,omitempty"`
 	Dockerfile string            `yaml:"dockerfile,omitempty"`
@@ -79,6 +92,18 @@ type BuildConfig struct {
 	Network    string            `yaml:"network,omitempty"`
 	ShmSize    string            `yaml:"shm_size,omitempty"`
 	Secrets    []string          `yaml:"secrets,omitempty"`
+
+	Extras map[string]interface{} `yaml:",inline"` // keys not modelled above (tags, platforms, ssh, additional_contexts, ...)
+
+	short bool // written as just the context path
+}
+
+// ExtendsConfig names the service a service extends, `extends: name` refers to a service in the same file
+type ExtendsConfig struct {
+	File    string `yaml:"file,omitempty"`
+	Service string `yaml:"service"`
+
+	short bool // written as just the service name
 }
 
 // NetworkConfig represents network configuration for a service
@@ -90,19 +115,25 @@ type NetworkConfig struct {
 
 // DependsOnConfig represents depends_on configuration
 type DependsOnConfig struct {
-	Condition string `yaml:"condition,omitempty"`
+	Condition string `yaml:"condition,omitempty"` // service_started, service_healthy or service_completed_successfully
+	Restart   *bool  `yaml:"restart,omitempty"`
+	Required  *bool  `yaml:"required,omitempty"` // defaults to true
+
+	Extras map[string]interface{} `yaml:",inline"` // keys not modelled above, including x- extensions
 }
 
 // DeployConfig represents deployment configuration
 type DeployConfig struct {
 	Mode          string               `yaml:"mode,omitempty"`
-	Replicas      int                  `yaml:"replicas,omitempty"`
+	Replicas      *int                 `yaml:"replicas,omitempty"` // 0 is kept, it disables the service
 	Labels        map[string]string    `yaml:"labels,omitempty"`
 	UpdateConfig  *UpdateConfig        `yaml:"update_config,omitempty"`
 	Resources     *ResourcesConfig     `yaml:"resources,omitempty"`
 	RestartPolicy *RestartPolicyConfig `yaml:"restart_policy,omitempty"`
 	Placement     *PlacementConfig     `yaml:"placement,omitempty"`
 	EndpointMode  string               `yaml:"endpoint_mode,omitempty"`
+
+	Extras map[string]interface{} `yaml:",inline"` // keys not modelled above (rollback_config, ...)
 }
 
 // UpdateConfig represents update configuration
@@ -113,12 +144,16 @@ type UpdateConfig struct {
 	Monitor         *time.Duration `yaml:"monitor,omitempty"`
 	MaxFailureRatio float64        `yaml:"max_failure_ratio,omitempty"`
 	Order           string         `yaml:"order,omitempty"`
+
+	Extras map[string]interface{} `yaml:",inline"` // keys not modelled above, including x- extensions
 }
 
 // ResourcesConfig represents resource constraints
 type ResourcesConfig struct {
 	Limits       *ResourceLimit `yaml:"limits,omitempty"`
 	Reservations *ResourceLimit `yaml:"reservations,omitempty"`
+
+	Extras map[string]interface{} `yaml:",inline"` // keys not modelled above, including x- extensions
 }
 
 // ResourceLimit represents resource limits
@@ -126,6 +161,8 @@ type ResourceLimit struct {
 	CPUs    string   `yaml:"cpus,omitempty"`
 	Memory  string   `yaml:"memory,omitempty"`
 	Devices []string `yaml:"devices,omitempty"`
+
+	Extras map[string]interface{} `yaml:",inline"` // keys not modelled above (pids, generic_resources, ...)
 }
 
 // RestartPolicyConfig represents restart policy
@@ -134,6 +171,8 @@ type RestartPolicyConfig struct {
 	Delay       *time.Duration `yaml:"delay,omitempty"`
 	MaxAttempts int            `yaml:"max_attempts,omitempty"`
 	Window      *time.Duration `yaml:"window,omitempty"`
+
+	Extras map[string]interface{} `yaml:",inline"` // keys not modelled above, including x- extensions
 }
 
 // PlacementConfig represents placement constraints
@@ -141,6 +180,8 @@ type PlacementConfig struct {
 	Constraints []string            `yaml:"constraints,omitempty"`
 	Preferences []map[string]string `yaml:"preferences,omitempty"`
 	MaxReplicas int                 `yaml:"max_replicas_per_node,omitempty"`
+
+	Extras map[string]interface{} `yaml:",inline"` // keys not modelled above, including x- extensions
 }
 
 // HealthCheckConfig represents health check configuration
@@ -148,9 +189,11 @@ type HealthCheckConfig struct {
 	Test        interface{}    `yaml:"test,omitempty"` // string or []string
 	Interval    *time.Duration `yaml:"interval,omitempty"`
 	Timeout     *time.Duration `yaml:"timeout,omitempty"`
-	Retries     int            `yaml:"retries,omitempty"`
+	Retries     *int           `yaml:"retries,omitempty"`
 	StartPeriod *time.Duration `yaml:"start_period,omitempty"`
-	Disable     bool           `yaml:"disable,omitempty"`
+	Disable     *bool          `yaml:"disable,omitempty"`
+
+	Extras map[string]interface{} `yaml:",inline"` // keys not modelled above (start_interval, ...)
 }
 
 // Network represents a network definition
@@ -164,6 +207,8 @@ type Network struct {
 	Attachable bool              `yaml:"attachable,omitempty"`
 	Internal   bool              `yaml:"internal,omitempty"`
 	Name       string            `yaml:"name,omitempty"`
+
+	Extras map[string]interface{} `yaml:",inline"` // keys not modelled above, including x- extensions
 }
 
 // IPAMConfig represents IPAM configuration
@@ -180,6 +225,8 @@ type Volume struct {
 	External   interface{}       `yaml:"external,omitempty"` // bool or map[string]string
 	Labels     map[string]string `yaml:"labels,omitempty"`
 	Name       string            `yaml:"name,omitempty"`
+
+	Extras map[string]interface{} `yaml:",inline"` // keys not modelled above, including x- extensions
 }
 
 // Secret represents a secret definition
@@ -188,6 +235,8 @@ type Secret struct {
 	External interface{}       `yaml:"external,omitempty"` // bool or map[string]string
 	Labels   map[string]string `yaml:"labels,omitempty"`
 	Name     string            `yaml:"name,omitempty"`
+
+	Extras map[string]interface{} `yaml:",inline"` // keys not modelled above, including x- extensions
 }
 
 // Config represents a config definition
@@ -196,4 +245,6 @@ type Config struct {
 	External interface{}       `yaml:"external,omitempty"` // bool or map[string]string
 	Labels   map[string]string `yaml:"labels,omitempty"`
 	Name     string            `yaml:"name,omitempty"`
+
+	Extras map[string]interface{} `yaml:",inline"` // keys not modelled above, including x- extensions
 }
@@ -0,0 +1,550 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"path"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Environment represents a service's environment variables.
+// Compose accepts either a list (`- KEY=value`, `- KEY`) or a map (`KEY: value`, `KEY:`),
+// a nil value marks a pass-through variable resolved from the shell when compose runs.
+type Environment map[string]*string
+
+// UnmarshalYAML accepts both the list and map syntax, including aliases and `<<` merge keys
+func (e *Environment) UnmarshalYAML(value *yaml.Node) error {
+	switch value.Kind {
+	case yaml.SequenceNode:
+		env := make(Environment)
+		for _, item := range value.Content {
+			if item.Kind == yaml.AliasNode {
+				item = item.Alias
+			}
+			if item.Kind != yaml.ScalarNode {
+				return fmt.Errorf("line %d: environment list entries must be strings", item.Line)
+			}
+			key, val, found := strings.Cut(item.Value, "=")
+			if found {
+				env[key] = &val
+			} else {
+				env[key] = nil
+			}
+		}
+		*e = env
+	case yaml.MappingNode:
+		env := map[string]*string{}
+		if err := value.Decode(&env); err != nil {
+			return err
+		}
+		*e = env
+	default:
+		return fmt.Errorf("line %d: environment must be a list or a map", value.Line)
+	}
+
+	return nil
+}
+
+// MarshalYAML always emits the map form, sorted by key, with pass-through variables left empty
+func (e Environment) MarshalYAML() (interface{}, error) {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for _, key := range slices.Sorted(maps.Keys(e)) {
+		val := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null"}
+		if v := e[key]; v != nil {
+			val = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: *v}
+		}
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, val)
+	}
+	return node, nil
+}
+
+// Merge returns a copy of e with every variable in other added, other taking precedence
+func (e Environment) Merge(other Environment) Environment {
+	if e == nil && other == nil {
+		return nil
+	}
+	result := make(Environment, len(e)+len(other))
+	maps.Copy(result, e)
+	maps.Copy(result, other)
+	return result
+}
+
+// EnvFiles lists the files a service reads environment variables from.
+// Compose accepts a single path, a list of paths, or a list of {path, required} entries.
+type EnvFiles []EnvFileConfig
+
+// EnvFileConfig is a single env_file entry
+type EnvFileConfig struct {
+	Path     string `yaml:"path"`
+	Required *bool  `yaml:"required,omitempty"` // defaults to true
+	Format   string `yaml:"format,omitempty"`
+}
+
+// UnmarshalYAML accepts a single path or a list of paths and long syntax entries
+func (e *EnvFiles) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*e = EnvFiles{{Path: value.Value}}
+		return nil
+	}
+	if value.Kind != yaml.SequenceNode {
+		return fmt.Errorf("line %d: env_file must be a string or a list", value.Line)
+	}
+
+	files := make(EnvFiles, 0, len(value.Content))
+	for _, item := range value.Content {
+		if item.Kind == yaml.ScalarNode {
+			files = append(files, EnvFileConfig{Path: item.Value})
+			continue
+		}
+		var file EnvFileConfig
+		if err := item.Decode(&file); err != nil {
+			return err
+		}
+		files = append(files, file)
+	}
+
+	*e = files
+	return nil
+}
+
+// MarshalYAML writes the short syntax for entries that only have a path
+func (f EnvFileConfig) MarshalYAML() (interface{}, error) {
+	if f.Required == nil && f.Format == "" {
+		return f.Path, nil
+	}
+
+	type plain EnvFileConfig
+	return plain(f), nil
+}
+
+// IsRequired reports whether compose must fail when the file is missing
+func (f EnvFileConfig) IsRequired() bool {
+	return f.Required == nil || *f.Required
+}
+
+// DependsOn maps a service's dependencies to how they are waited on.
+// The list form (`- db`) is normalised to the map form with the service_started condition.
+type DependsOn map[string]DependsOnConfig
+
+// UnmarshalYAML accepts both the list and map syntax
+func (d *DependsOn) UnmarshalYAML(value *yaml.Node) error {
+	switch value.Kind {
+	case yaml.SequenceNode:
+		var names []string
+		if err := value.Decode(&names); err != nil {
+			return err
+		}
+		deps := make(DependsOn, len(names))
+		for _, name := range names {
+			deps[name] = DependsOnConfig{Condition: "service_started"}
+		}
+		*d = deps
+		return nil
+	case yaml.MappingNode:
+		deps := make(map[string]DependsOnConfig)
+		if err := value.Decode(&deps); err != nil {
+			return err
+		}
+		*d = deps
+		return nil
+	default:
+		return fmt.Errorf("line %d: depends_on must be a list or a map", value.Line)
+	}
+}
+
+// IsRequired reports whether compose must fail when the dependency is missing
+func (c DependsOnConfig) IsRequired() bool {
+	return c.Required == nil || *c.Required
+}
+
+// StringList is a value compose accepts as either a single string or a list of strings
+type StringList []string
+
+// UnmarshalYAML accepts both a single string and a list
+func (l *StringList) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*l = StringList{value.Value}
+		return nil
+	}
+
+	var list []string
+	err := value.Decode(&list)
+	*l = list
+	return err
+}
+
+// UnmarshalYAML accepts both the short path and the long mapping syntax
+func (i *IncludeConfig) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*i = IncludeConfig{Path: StringList{value.Value}}
+		return nil
+	}
+
+	type plain IncludeConfig
+	return value.Decode((*plain)(i))
+}
+
+// UnmarshalYAML accepts both the short context string and the long mapping syntax
+func (b *BuildConfig) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*b = BuildConfig{Context: value.Value, short: true}
+		return nil
+	}
+
+	type plain BuildConfig
+	return value.Decode((*plain)(b))
+}
+
+// MarshalYAML writes the short syntax if the build was written that way and still only has a context
+func (b BuildConfig) MarshalYAML() (interface{}, error) {
+	if b.short && reflect.DeepEqual(b, BuildConfig{Context: b.Context, short: true}) {
+		return b.Context, nil
+	}
+
+	type plain BuildConfig
+	return plain(b), nil
+}
+
+// UnmarshalYAML accepts both the short service name and the long mapping syntax
+func (e *ExtendsConfig) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*e = ExtendsConfig{Service: value.Value, short: true}
+		return nil
+	}
+
+	type plain ExtendsConfig
+	return value.Decode((*plain)(e))
+}
+
+// MarshalYAML writes the short syntax if the extends was written that way and still has no file
+func (e ExtendsConfig) MarshalYAML() (interface{}, error) {
+	if e.short && e.File == "" {
+		return e.Service, nil
+	}
+
+	type plain ExtendsConfig
+	return plain(e), nil
+}
+
+// ServicePortConfig is a single entry of a service's ports list.
+// The short syntax ("[HOST_IP:][PUBLISHED:]TARGET[/PROTOCOL]") is parsed into the same fields
+// as the long syntax, and written back unchanged when none of its fields were modified.
+type ServicePortConfig struct {
+	Name        string      `yaml:"name,omitempty"`
+	Target      uint32      `yaml:"target,omitempty"`
+	Published   IntOrString `yaml:"published,omitempty"`
+	HostIP      string      `yaml:"host_ip,omitempty"`
+	Protocol    string      `yaml:"protocol,omitempty"`
+	AppProtocol string      `yaml:"app_protocol,omitempty"`
+	Mode        string      `yaml:"mode,omitempty"`
+
+	short       string // original short syntax, if the port was written that way
+	targetRange string // container port range ("3000-3005") from the short syntax, Target is unset
+}
+
+// UnmarshalYAML accepts both the short string and the long mapping syntax
+func (p *ServicePortConfig) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		port, err := parsePortShort(value.Value)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", value.Line, err)
+		}
+		*p = port
+		return nil
+	}
+
+	type plain ServicePortConfig
+	return value.Decode((*plain)(p))
+}
+
+// MarshalYAML writes the original short syntax if it still describes the port, otherwise the long syntax
+func (p ServicePortConfig) MarshalYAML() (interface{}, error) {
+	if p.short != "" {
+		if port, err := parsePortShort(p.short); err == nil && port == p {
+			return shortScalar(p.short), nil
+		}
+	}
+
+	type plain ServicePortConfig
+	return plain(p), nil
+}
+
+// Key identifies the container side of the port as "target/protocol", used to merge ports across files.
+// The protocol defaults to tcp, so "8080:8080" and "8080:8080/tcp" share a key.
+func (p ServicePortConfig) Key() string {
+	protocol := strings.ToLower(p.Protocol)
+	if protocol == "" {
+		protocol = "tcp"
+	}
+
+	target := p.targetRange
+	if target == "" {
+		target = strconv.FormatUint(uint64(p.Target), 10)
+	}
+	return fmt.Sprintf("%s/%s", target, protocol)
+}
+
+func parsePortShort(s string) (ServicePortConfig, error) {
+	port := ServicePortConfig{short: s}
+
+	rest := s
+	if i := strings.LastIndex(rest, "/"); i >= 0 {
+		port.Protocol = rest[i+1:]
+		rest = rest[:i]
+	}
+
+	target := rest
+	if i := strings.LastIndex(rest, ":"); i >= 0 {
+		target = rest[i+1:]
+		rest = rest[:i]
+		if j := strings.LastIndex(rest, ":"); j >= 0 && !strings.HasSuffix(rest, "]") {
+			port.Published = IntOrString(rest[j+1:])
+			port.HostIP = strings.Trim(rest[:j], "[]")
+		} else if strings.HasPrefix(rest, "[") {
+			port.HostIP = strings.Trim(rest, "[]")
+		} else {
+			port.Published = IntOrString(rest)
+		}
+	}
+
+	if target == "" {
+		return port, fmt.Errorf("invalid port %q: missing container port", s)
+	}
+	// Ranges ("3000-3005") have no single target
+	if strings.Contains(target, "-") {
+		port.targetRange = target
+	} else {
+		n, err := strconv.ParseUint(target, 10, 32)
+		if err != nil {
+			return port, fmt.Errorf("invalid port %q: %w", s, err)
+		}
+		port.Target = uint32(n)
+	}
+
+	return port, nil
+}
+
+// ServiceVolumeConfig is a single entry of a service's volumes list.
+// The short syntax ("[SOURCE:]TARGET[:MODE]") is parsed into the same fields as the long syntax,
+// and written back unchanged when none of its fields were modified.
+type ServiceVolumeConfig struct {
+	Type        string                     `yaml:"type,omitempty"` // volume, bind, tmpfs, npipe or cluster
+	Source      string                     `yaml:"source,omitempty"`
+	Target      string                     `yaml:"target,omitempty"`
+	ReadOnly    bool                       `yaml:"read_only,omitempty"`
+	Consistency string                     `yaml:"consistency,omitempty"`
+	Bind        *ServiceVolumeBindConfig   `yaml:"bind,omitempty"`
+	Volume      *ServiceVolumeVolumeConfig `yaml:"volume,omitempty"`
+	Tmpfs       *ServiceVolumeTmpfsConfig  `yaml:"tmpfs,omitempty"`
+
+	short string // original short syntax, if the volume was written that way
+}
+
+// ServiceVolumeBindConfig represents the bind options of a volume
+type ServiceVolumeBindConfig struct {
+	Propagation    string `yaml:"propagation,omitempty"`
+	CreateHostPath bool   `yaml:"create_host_path,omitempty"`
+	SELinux        string `yaml:"selinux,omitempty"`
+}
+
+// ServiceVolumeVolumeConfig represents the named volume options of a volume
+type ServiceVolumeVolumeConfig struct {
+	NoCopy  bool   `yaml:"nocopy,omitempty"`
+	Subpath string `yaml:"subpath,omitempty"`
+}
+
+// ServiceVolumeTmpfsConfig represents the tmpfs options of a volume
+type ServiceVolumeTmpfsConfig struct {
+	Size IntOrString `yaml:"size,omitempty"`
+	Mode FileMode    `yaml:"mode,omitempty"`
+}
+
+// UnmarshalYAML accepts both the short string and the long mapping syntax
+func (v *ServiceVolumeConfig) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		volume, err := parseVolumeShort(value.Value)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", value.Line, err)
+		}
+		*v = volume
+		return nil
+	}
+
+	type plain ServiceVolumeConfig
+	return value.Decode((*plain)(v))
+}
+
+// MarshalYAML writes the original short syntax if it still describes the volume, otherwise the long syntax
+func (v ServiceVolumeConfig) MarshalYAML() (interface{}, error) {
+	if v.short != "" {
+		if volume, err := parseVolumeShort(v.short); err == nil && reflect.DeepEqual(volume, v) {
+			return shortScalar(v.short), nil
+		}
+	}
+
+	type plain ServiceVolumeConfig
+	return plain(v), nil
+}
+
+// Key identifies the mount point inside the container, used to merge volumes across files.
+// The path is cleaned so "/data/" and "/data" share a key.
+func (v ServiceVolumeConfig) Key() string {
+	return path.Clean(v.Target)
+}
+
+// setSource changes the source, keeping the short syntax when the volume was written that way
+func (v *ServiceVolumeConfig) setSource(source string) {
+	if v.short != "" && strings.HasPrefix(v.short, v.Source) {
+		v.short = source + strings.TrimPrefix(v.short, v.Source)
+	}
+	v.Source = source
+}
+
+func parseVolumeShort(s string) (ServiceVolumeConfig, error) {
+	volume := ServiceVolumeConfig{Type: "volume", short: s}
+
+	parts := strings.Split(s, ":")
+	switch len(parts) {
+	case 1:
+		volume.Target = parts[0]
+	case 2, 3:
+		volume.Source = parts[0]
+		volume.Target = parts[1]
+	default:
+		return volume, fmt.Errorf("invalid volume %q: too many colons", s)
+	}
+	if volume.Target == "" {
+		return volume, fmt.Errorf("invalid volume %q: missing container path", s)
+	}
+
+	if strings.HasPrefix(volume.Source, "/") || strings.HasPrefix(volume.Source, ".") || strings.HasPrefix(volume.Source, "~") {
+		volume.Type = "bind"
+	}
+
+	if len(parts) < 3 {
+		return volume, nil
+	}
+	for _, opt := range strings.Split(parts[2], ",") {
+		switch opt {
+		case "ro":
+			volume.ReadOnly = true
+		case "rw":
+		case "z", "Z":
+			if volume.Bind == nil {
+				volume.Bind = &ServiceVolumeBindConfig{}
+			}
+			volume.Bind.SELinux = opt
+		case "shared", "rshared", "slave", "rslave", "private", "rprivate":
+			if volume.Bind == nil {
+				volume.Bind = &ServiceVolumeBindConfig{}
+			}
+			volume.Bind.Propagation = opt
+		case "nocopy":
+			volume.Volume = &ServiceVolumeVolumeConfig{NoCopy: true}
+		case "consistent", "cached", "delegated":
+			volume.Consistency = opt
+		default:
+			return volume, fmt.Errorf("invalid volume %q: unknown mode %q", s, opt)
+		}
+	}
+
+	return volume, nil
+}
+
+// ServiceFileReferenceConfig is a single entry of a service's secrets or configs list.
+// The short syntax is just the name of the top level secret or config.
+type ServiceFileReferenceConfig struct {
+	Source string   `yaml:"source,omitempty"`
+	Target string   `yaml:"target,omitempty"`
+	UID    string   `yaml:"uid,omitempty"`
+	GID    string   `yaml:"gid,omitempty"`
+	Mode   FileMode `yaml:"mode,omitempty"`
+}
+
+// UnmarshalYAML accepts both the short string and the long mapping syntax
+func (f *ServiceFileReferenceConfig) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*f = ServiceFileReferenceConfig{Source: value.Value}
+		return nil
+	}
+
+	type plain ServiceFileReferenceConfig
+	return value.Decode((*plain)(f))
+}
+
+// MarshalYAML writes the short syntax when only the source is set
+func (f ServiceFileReferenceConfig) MarshalYAML() (interface{}, error) {
+	if f == (ServiceFileReferenceConfig{Source: f.Source}) {
+		return f.Source, nil
+	}
+
+	type plain ServiceFileReferenceConfig
+	return plain(f), nil
+}
+
+// Key identifies where the file is mounted in the container, defaulting to dir/source
+func (f ServiceFileReferenceConfig) Key(dir string) string {
+	if f.Target != "" {
+		return f.Target
+	}
+	return path.Join(dir, f.Source)
+}
+
+// FileMode is an octal file mode, kept exactly as written (e.g. 0440) so it round-trips unchanged
+type FileMode string
+
+// MarshalYAML writes the mode as a plain integer rather than a quoted string
+func (m FileMode) MarshalYAML() (interface{}, error) {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: string(m)}, nil
+}
+
+// IntOrString holds a value compose accepts as either a number or a string (e.g. published: 8080 or "8080-8081"),
+// numbers are written back unquoted
+type IntOrString string
+
+// MarshalYAML writes numeric values as plain integers
+func (s IntOrString) MarshalYAML() (interface{}, error) {
+	return shortScalar(string(s)), nil
+}
+
+// shortScalar returns a short syntax value as a node, keeping bare numbers (e.g. `- 3000`) unquoted
+func shortScalar(s string) *yaml.Node {
+	if _, err := strconv.ParseUint(s, 10, 32); err == nil {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: s}
+	}
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: s}
+}
+
+// mergeByKey returns a with the entries of b merged in, the later file winning: the entries of b replace every
+// entry of a sharing their key, in the position of the first. Entries within one list are never collapsed,
+// so a file may bind the same container port to several host addresses.
+func mergeByKey[T any](a, b []T, key func(T) string) []T {
+	replacements := map[string][]T{}
+	for _, item := range b {
+		k := key(item)
+		replacements[k] = append(replacements[k], item)
+	}
+
+	result := make([]T, 0, len(a)+len(b))
+	placed := map[string]bool{}
+	for _, item := range a {
+		k := key(item)
+		if _, replaced := replacements[k]; !replaced {
+			result = append(result, item)
+		} else if !placed[k] {
+			result = append(result, replacements[k]...)
+			placed[k] = true
+		}
+	}
+	for _, item := range b {
+		if !placed[key(item)] {
+			result = append(result, item)
+		}
+	}
+	return result
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestEnvironmentUnmarshal(t *testing.T) {
+	a, empty := "1", ""
+	cases := []struct {
+		name string
+		doc  string
+		want Environment
+	}{
+		{
+			name: "list",
+			doc:  "environment: [A=1, B=, C]",
+			want: Environment{"A": &a, "B": &empty, "C": nil},
+		},
+		{
+			name: "map",
+			doc:  "environment: {A: 1, B: '', C: }",
+			want: Environment{"A": &a, "B": &empty, "C": nil},
+		},
+		{
+			name: "merge key",
+			doc: `
+common: &common
+  A: "1"
+  C: "2"
+environment:
+  <<: *common
+  C:
+`,
+			want: Environment{"A": &a, "C": nil},
+		},
+		{
+			name: "aliased map value",
+			doc: `
+value: &value "1"
+environment:
+  A: *value
+`,
+			want: Environment{"A": &a},
+		},
+		{
+			name: "aliased list entry",
+			doc: `
+value: &value A=1
+environment:
+  - *value
+  - C
+`,
+			want: Environment{"A": &a, "C": nil},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var doc struct {
+				Environment Environment `yaml:"environment"`
+			}
+			if err := yaml.Unmarshal([]byte(tc.doc), &doc); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(doc.Environment, tc.want) {
+				t.Errorf("got %v, want %v", envValues(doc.Environment), envValues(tc.want))
+			}
+		})
+	}
+}
+
+// envValues dereferences an environment for readable failure messages
+func envValues(env Environment) map[string]interface{} {
+	values := map[string]interface{}{}
+	for key, val := range env {
+		if val == nil {
+			values[key] = nil
+		} else {
+			values[key] = *val
+		}
+	}
+	return values
+}
+
+func TestParsePortShort(t *testing.T) {
+	cases := []struct {
+		in      string
+		want    ServicePortConfig
+		wantKey string
+		wantErr bool
+	}{
+		{in: "3000", want: ServicePortConfig{Target: 3000}, wantKey: "3000/tcp"},
+		{in: "8080:80", want: ServicePortConfig{Target: 80, Published: "8080"}, wantKey: "80/tcp"},
+		{in: "8080:80/UDP", want: ServicePortConfig{Target: 80, Published: "8080", Protocol: "UDP"}, wantKey: "80/udp"},
+		{in: "127.0.0.1:8080:80", want: ServicePortConfig{Target: 80, Published: "8080", HostIP: "127.0.0.1"}, wantKey: "80/tcp"},
+		{in: "127.0.0.1::80", want: ServicePortConfig{Target: 80, HostIP: "127.0.0.1"}, wantKey: "80/tcp"},
+		{in: "[::1]:5432:5432", want: ServicePortConfig{Target: 5432, Published: "5432", HostIP: "::1"}, wantKey: "5432/tcp"},
+		{in: "[::1]::5432", want: ServicePortConfig{Target: 5432, HostIP: "::1"}, wantKey: "5432/tcp"},
+		{in: "3000-3005", want: ServicePortConfig{targetRange: "3000-3005"}, wantKey: "3000-3005/tcp"},
+		{in: "9090-9091:8080-8081", want: ServicePortConfig{Published: "9090-9091", targetRange: "8080-8081"}, wantKey: "8080-8081/tcp"},
+		{in: "8080:", wantErr: true},
+		{in: "8080:http", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.in, func(t *testing.T) {
+			got, err := parsePortShort(tc.in)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			tc.want.short = tc.in
+			if got != tc.want {
+				t.Errorf("got %+v, want %+v", got, tc.want)
+			}
+			if got.Key() != tc.wantKey {
+				t.Errorf("got key %q, want %q", got.Key(), tc.wantKey)
+			}
+			assertShortRoundTrip(t, got, tc.in)
+		})
+	}
+}
+
+func TestParseVolumeShort(t *testing.T) {
+	cases := []struct {
+		in      string
+		want    ServiceVolumeConfig
+		wantErr bool
+	}{
+		{in: "/data", want: ServiceVolumeConfig{Type: "volume", Target: "/data"}},
+		{in: "data:/data", want: ServiceVolumeConfig{Type: "volume", Source: "data", Target: "/data"}},
+		{in: "data:/data:nocopy", want: ServiceVolumeConfig{Type: "volume", Source: "data", Target: "/data", Volume: &ServiceVolumeVolumeConfig{NoCopy: true}}},
+		{in: "./src:/app:ro", want: ServiceVolumeConfig{Type: "bind", Source: "./src", Target: "/app", ReadOnly: true}},
+		{in: "/host:/c:rw,z,rshared", want: ServiceVolumeConfig{Type: "bind", Source: "/host", Target: "/c", Bind: &ServiceVolumeBindConfig{SELinux: "z", Propagation: "rshared"}}},
+		{in: "~/x:/x:cached", want: ServiceVolumeConfig{Type: "bind", Source: "~/x", Target: "/x", Consistency: "cached"}},
+		{in: "a:/b:c:d", wantErr: true},
+		{in: "data:", wantErr: true},
+		{in: "data:/data:bogus", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.in, func(t *testing.T) {
+			got, err := parseVolumeShort(tc.in)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			tc.want.short = tc.in
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %+v, want %+v", got, tc.want)
+			}
+			assertShortRoundTrip(t, got, tc.in)
+		})
+	}
+}
+
+// assertShortRoundTrip checks that an unmodified short syntax entry is written back exactly as it was read
+func assertShortRoundTrip(t *testing.T, value interface{}, short string) {
+	t.Helper()
+	data, err := yaml.Marshal([]interface{}{value})
+	if err != nil {
+		t.Fatalf("error marshaling: %v", err)
+	}
+	var written []string
+	if err := yaml.Unmarshal(data, &written); err != nil || len(written) != 1 || written[0] != short {
+		t.Errorf("written back as %s, want %q", data, short)
+	}
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Variable interpolation follows the compose syntax:
+//
+//	$VAR, ${VAR}          value of VAR
+//	${VAR:-default}       default if VAR is unset or empty, ${VAR-default} only if unset
+//	${VAR:?error}         fail if VAR is unset or empty, ${VAR?error} only if unset
+//	${VAR:+replacement}   replacement if VAR is set and not empty, ${VAR+replacement} if set
+//	$$                    a literal $, kept escaped as the output is itself a compose file
+//
+// Defaults, errors and replacements may contain further variables.
+
+// loadVariables builds the interpolation variables from the env files, the process
+// environment (if enabled) and the request Args, later sources taking precedence
+func (dc *DockerComposeCompiler) loadVariables() error {
+	variables := map[string]string{}
+
+	for _, f := range dc.Config.EnvFiles {
+		envPath := dc.resolvePath(f)
+		fileVars, err := readEnvVariables(envPath)
+		if err != nil {
+			return fmt.Errorf("error reading env file:\n\tpath:%s\n\terror:%w", envPath, err)
+		}
+		for k, v := range fileVars {
+			variables[k] = v
+		}
+	}
+
+	if dc.Config.UseProcessEnv {
+		for _, kv := range os.Environ() {
+			if k, v, ok := strings.Cut(kv, "="); ok {
+				variables[k] = v
+			}
+		}
+	}
+
+	for k, v := range dc.Args {
+		variables[k] = v
+	}
+
+	dc.Variables = variables
+	return nil
+}
+
+// interpolateNode substitutes variables in every scalar value below node, mapping keys are left as written
+func (dc *DockerComposeCompiler) interpolateNode(node *yaml.Node) (*yaml.Node, error) {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			if failed, err := dc.interpolateNode(child); err != nil {
+				return failed, err
+			}
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			if failed, err := dc.interpolateNode(node.Content[i]); err != nil {
+				return failed, err
+			}
+		}
+	case yaml.ScalarNode:
+		if !strings.Contains(node.Value, "$") {
+			return nil, nil
+		}
+		value, err := dc.interpolate(node.Value)
+		if err != nil {
+			return node, err
+		}
+		if value != node.Value {
+			node.Value = value
+			// Let plain scalars be re-resolved, so "${PORT}" can decode as an integer
+			if node.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle|yaml.LiteralStyle|yaml.FoldedStyle) == 0 {
+				node.Tag = ""
+			}
+		}
+	}
+	return nil, nil
+}
+
+// interpolate substitutes every variable in s
+func (dc *DockerComposeCompiler) interpolate(s string) (string, error) {
+	var out strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			out.WriteByte(s[i])
+			continue
+		}
+
+		switch next := s[i+1]; {
+		case next == '$':
+			out.WriteString("$$")
+			i++
+		case next == '{':
+			end := closingBrace(s, i+2)
+			if end < 0 {
+				return "", fmt.Errorf("unterminated variable in %q", s)
+			}
+			value, err := dc.expand(s[i:end+1], s[i+2:end])
+			if err != nil {
+				return "", err
+			}
+			out.WriteString(value)
+			i = end
+		case isNameStart(next):
+			end := i + 2
+			for end < len(s) && isNameChar(s[end]) {
+				end++
+			}
+			value, err := dc.expand(s[i:end], s[i+1:end])
+			if err != nil {
+				return "", err
+			}
+			out.WriteString(value)
+			i = end - 1
+		default:
+			out.WriteByte('$')
+		}
+	}
+
+	return out.String(), nil
+}
+
+// expand resolves a single variable expression, raw is the expression as written and expr the part inside any braces
+func (dc *DockerComposeCompiler) expand(raw, expr string) (string, error) {
+	name := expr
+	op, arg := "", ""
+	for i := 0; i < len(expr); i++ {
+		if isNameChar(expr[i]) {
+			continue
+		}
+		name = expr[:i]
+		rest := expr[i:]
+		for _, candidate := range []string{":-", ":?", ":+", "-", "?", "+"} {
+			if strings.HasPrefix(rest, candidate) {
+				op, arg = candidate, rest[len(candidate):]
+				break
+			}
+		}
+		if op == "" {
+			return "", fmt.Errorf("invalid variable expression %q", raw)
+		}
+		break
+	}
+	if name == "" {
+		return "", fmt.Errorf("invalid variable expression %q", raw)
+	}
+
+	value, set := dc.Variables[name]
+	if !set && dc.Config.KeepUnresolved {
+		// Leave the whole expression for compose to resolve at runtime
+		return raw, nil
+	}
+
+	switch op {
+	case "":
+		if !set {
+			dc.warn("variable %s is not set, substituting an empty string", name)
+		}
+		return value, nil
+	case ":-":
+		if value == "" {
+			return dc.interpolate(arg)
+		}
+	case "-":
+		if !set {
+			return dc.interpolate(arg)
+		}
+	case ":?":
+		if value == "" {
+			return "", dc.requiredVariableError(name, arg)
+		}
+	case "?":
+		if !set {
+			return "", dc.requiredVariableError(name, arg)
+		}
+	case ":+":
+		if value == "" {
+			return "", nil
+		}
+		return dc.interpolate(arg)
+	case "+":
+		if !set {
+			return "", nil
+		}
+		return dc.interpolate(arg)
+	}
+
+	return value, nil
+}
+
+func (dc *DockerComposeCompiler) requiredVariableError(name, message string) error {
+	message, err := dc.interpolate(message)
+	if err != nil {
+		return err
+	}
+	if message == "" {
+		return fmt.Errorf("required variable %s is missing a value", name)
+	}
+	return fmt.Errorf("required variable %s is missing a value: %s", name, message)
+}
+
+// closingBrace returns the index of the brace closing a ${ that started before from, allowing nested ${...}
+func closingBrace(s string, from int) int {
+	depth := 1
+	for i := from; i < len(s); i++ {
+		switch {
+		case s[i] == '$' && i+1 < len(s) && s[i+1] == '{':
+			depth++
+			i++
+		case s[i] == '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isNameChar(c byte) bool {
+	return isNameStart(c) || (c >= '0' && c <= '9')
+}
+
+// readEnvFile parses a .env file: KEY=VALUE lines, optionally prefixed with "export",
+// with single quoted values taken literally and double quoted values supporting \n, \" and \\.
+// Blank lines and lines starting with # are ignored, as is a " #" comment after an unquoted value.
+// A bare KEY line is returned unset, compose passes it through from the shell.
+func readEnvFile(path string) (Environment, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	variables := Environment{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, found := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if key == "" {
+			return nil, fmt.Errorf("line %d: missing variable name", lineNumber)
+		}
+		if !found {
+			variables[key] = nil
+			continue
+		}
+
+		value, err = parseEnvValue(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		variables[key] = &value
+	}
+
+	return variables, scanner.Err()
+}
+
+// readEnvVariables reads a .env file for interpolation, where a bare KEY line has no value to contribute
+func readEnvVariables(path string) (map[string]string, error) {
+	env, err := readEnvFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	variables := make(map[string]string, len(env))
+	for key, value := range env {
+		if value != nil {
+			variables[key] = *value
+		}
+	}
+	return variables, nil
+}
+
+func parseEnvValue(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+
+	switch quote := value[0]; quote {
+	case '\'':
+		end := strings.IndexByte(value[1:], '\'')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated quoted value")
+		}
+		return value[1 : end+1], nil
+	case '"':
+		var out strings.Builder
+		for i := 1; i < len(value); i++ {
+			switch c := value[i]; {
+			case c == '"':
+				return out.String(), nil
+			case c == '\\' && i+1 < len(value):
+				i++
+				switch value[i] {
+				case 'n':
+					out.WriteByte('\n')
+				case 't':
+					out.WriteByte('\t')
+				default:
+					out.WriteByte(value[i])
+				}
+			default:
+				out.WriteByte(c)
+			}
+		}
+		return "", fmt.Errorf("unterminated quoted value")
+	}
+
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = strings.TrimSpace(value[:i])
+	}
+	return value, nil
+}
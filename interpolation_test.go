@@ -0,0 +1,147 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInterpolate(t *testing.T) {
+	variables := map[string]string{"SET": "value", "EMPTY": "", "PORT": "8080"}
+	cases := []struct {
+		name           string
+		in             string
+		want           string
+		wantErr        string
+		keepUnresolved bool
+	}{
+		{name: "no variables", in: "plain text", want: "plain text"},
+		{name: "braced", in: "${SET}", want: "value"},
+		{name: "unbraced", in: "$SET/x", want: "value/x"},
+		{name: "unbraced stops at a non name character", in: "$PORT-$SET.", want: "8080-value."},
+		{name: "unset is empty", in: "a${UNSET}b", want: "ab"},
+		{name: "escaped dollar is kept for compose", in: "$$SET", want: "$$SET"},
+		{name: "lone dollar", in: "cost: 5$ or $", want: "cost: 5$ or $"},
+		{name: "default when unset or empty", in: "${EMPTY:-d} ${UNSET:-d} ${SET:-d}", want: "d d value"},
+		{name: "default only when unset", in: "${EMPTY-d} ${UNSET-d} ${SET-d}", want: " d value"},
+		{name: "replacement when set and not empty", in: "${EMPTY:+r} ${UNSET:+r} ${SET:+r}", want: "  r"},
+		{name: "replacement when set", in: "${EMPTY+r} ${UNSET+r} ${SET+r}", want: "r  r"},
+		{name: "nested default", in: "${UNSET:-${SET}-${PORT}}", want: "value-8080"},
+		{name: "required set", in: "${SET:?needed}", want: "value"},
+		{name: "required empty", in: "${EMPTY:?needed}", wantErr: "required variable EMPTY is missing a value: needed"},
+		{name: "required unset", in: "${UNSET?}", wantErr: "required variable UNSET is missing a value"},
+		{name: "required allows empty", in: "${EMPTY?needed}", want: ""},
+		{name: "unterminated", in: "${SET", wantErr: "unterminated variable"},
+		{name: "invalid expression", in: "${SET!}", wantErr: "invalid variable expression"},
+		{name: "missing name", in: "${:-d}", wantErr: "invalid variable expression"},
+		{name: "keep unresolved", in: "${UNSET:-d} ${SET}", want: "${UNSET:-d} value", keepUnresolved: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			dc := &DockerComposeCompiler{
+				Config:    &Options{KeepUnresolved: tc.keepUnresolved},
+				Variables: variables,
+			}
+
+			got, err := dc.interpolate(tc.in)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestReadEnvFile(t *testing.T) {
+	cases := []struct {
+		name    string
+		content string
+		want    map[string]interface{} // nil values are unset, pass-through variables
+		wantErr bool
+	}{
+		{
+			name:    "plain values and comments",
+			content: "# comment\n\nA=1\n  B = two  \nC=three # trailing\nD=four#five\n",
+			want:    map[string]interface{}{"A": "1", "B": "two", "C": "three", "D": "four#five"},
+		},
+		{
+			name:    "export prefix and empty value",
+			content: "export A=1\nB=\n",
+			want:    map[string]interface{}{"A": "1", "B": ""},
+		},
+		{
+			name:    "single quotes are literal",
+			content: `A='x \n # y'` + "\n",
+			want:    map[string]interface{}{"A": `x \n # y`},
+		},
+		{
+			name:    "double quotes support escapes",
+			content: `A="x\ny \"z\" \\ \t"` + "\n",
+			want:    map[string]interface{}{"A": "x\ny \"z\" \\ \t"},
+		},
+		{
+			name:    "bare key is unset",
+			content: "A\nexport B\nC=1\n",
+			want:    map[string]interface{}{"A": nil, "B": nil, "C": "1"},
+		},
+		{
+			name:    "unterminated quote",
+			content: "A=\"x\n",
+			wantErr: true,
+		},
+		{
+			name:    "missing name",
+			content: "=1\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), ".env")
+			if err := os.WriteFile(path, []byte(tc.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			env, err := readEnvFile(path)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", envValues(env))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := envValues(env); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestReadEnvVariablesSkipsBareKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(path, []byte("A\nB=1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	variables, err := readEnvVariables(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := map[string]string{"B": "1"}; !reflect.DeepEqual(variables, want) {
+		t.Errorf("got %v, want %v", variables, want)
+	}
+}
@@ -20,7 +20,7 @@ type Options struct {
 	Action    string   `json:"action"`
 	Base      string   `json:"base"`
 	Output    string   `json:"output"`
-	Overrides []string `json:"overrides"`
+	Overrides []string `json:"overrides"` // override files (relative to projectPath) applied in order after the projects
 
 	ProjectPath   string   `json:"projectPath"`   // Path to the root of you project
 	ProjectFolder string   `json:"projectFolder"` // name of the folder within your projects name
@@ -28,6 +28,34 @@ type Options struct {
 	BasePath      string   `json:"basePath"`      // path from your project root to the folder containing a base level file (docker-compose.yml etc)
 	ConfigFolder  string   `json:"configFolder"`  // name of folder containing config
 
+	ProjectServices map[string][]string `json:"projectServices"` // services to take from a project's file, keyed by project (default: every service in the file)
+
+	// Project discovery, in addition to Projects. Discovered projects are merged after those listed, sorted by name.
+	ProjectGlobs     []string `json:"projectGlobs"`     // glob patterns (relative to projectPath) matching project directories or compose files, e.g. "services/*"
+	ProjectExcludes  []string `json:"projectExcludes"`  // glob patterns matched against a discovered project's directory (relative to projectPath)
+	ComposeFileNames []string `json:"composeFileNames"` // compose file names looked for in a project directory, in order (default ["docker-compose.yml"])
+
+	// Output locations, Go templates over OutputTemplateData. Relative results are resolved against ProjectPath.
+	OutputDir      string `json:"outputDir"`      // directory the merged file is written to (default "{{.ProjectPath}}/{{.Output}}/docker")
+	OutputFileName string `json:"outputFileName"` // name of the merged file (default "docker-compose.yml")
+	SecretsDir     string `json:"secretsDir"`     // directory secret files are expected in (default "{{.ProjectPath}}/{{.Output}}/config")
+
+	DryRun     bool   `json:"dryRun"`     // run the full merge but return the YAML instead of writing it
+	Check      bool   `json:"check"`      // refuse to overwrite an output file that was edited by hand since it was generated
+	Strictness string `json:"strictness"` // "strict" fails on any unreadable project, "lenient" (default) skips and reports it
+
+	// Variable interpolation, values come from EnvFiles, then the process environment, then the request args
+	Interpolate    bool     `json:"interpolate"`    // substitute ${VAR} expressions while reading compose files
+	EnvFiles       []string `json:"envFiles"`       // .env files (relative to projectPath) to read variables from
+	UseProcessEnv  bool     `json:"useProcessEnv"`  // also read variables from the plugin's environment
+	KeepUnresolved bool     `json:"keepUnresolved"` // leave expressions for unset variables as written, for compose to resolve at runtime
+
+	InlineEnvFiles bool   `json:"inlineEnvFiles"` // read each service's env_file into its environment, for an output that needs no other files
+	PathStyle      string `json:"pathStyle"`      // "relative" (default) writes rebased paths relative to the output file, "absolute" as absolute paths
+
+	PreserveComments bool `json:"preserveComments"` // carry comments and quoting from the source files into the output
+	KeepAnchors      bool `json:"keepAnchors"`      // write anchors, aliases and merge keys from the source files back where the merged values still match
+
 }
 
 type ExecutionRequestBody struct {
@@ -47,14 +75,24 @@ func (g *ExecuterImpl) Execute(ctx context.Context, body string) (string, error)
 		return "", err
 	}
 
-	if request.Options.Action == "merge" {
-		Merge(request)
-	} else {
+	log.Printf("Plugin: Execute called with body: \n\tArguments:%s\n\tInput: %s\n\tOptions: %+v", request.Args, request.Input, request.Options)
+
+	var result string
+	switch request.Options.Action {
+	case "merge":
+		result, err = Merge(request)
+	case "diff":
+		result, err = Diff(request)
+	case "validate":
+		result, err = Validate(request)
+	default:
 		return "", fmt.Errorf("%s action not found", request.Options.Action)
 	}
+	if err != nil {
+		return "", fmt.Errorf("%s action failed: %w", request.Options.Action, err)
+	}
 
-	log.Printf("Plugin: Execute called with body: \n\tArguments:%s\n\tInput: %s\n\tOptions: %s", request.Args, request.Input, request.Options)
-	return "Success", nil
+	return result, nil
 }
 
 func main() {
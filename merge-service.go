@@ -0,0 +1,391 @@
+package main
+
+import (
+	"maps"
+	"slices"
+)
+
+// Service merge rules follow the compose-spec (https://github.com/compose-spec/compose-spec/blob/main/13-merge.md):
+//   - scalars from the second service override the first when set
+//   - mappings are merged key by key, the second service winning
+//   - sequences are either merged (unique values, or by key) or replaced, depending on the field
+
+// unionExtraLists are the service keys not modelled by Service whose lists compose merges as a union
+var unionExtraLists = []string{"cap_add", "cap_drop", "group_add", "profiles"}
+
+// mergeServices combines two services, with the second taking precedence for conflicting fields
+func (dc *DockerComposeCompiler) mergeServices(a, b Service) Service {
+	result := a // Start with first service
+
+	// Scalars
+	result.Image = override(a.Image, b.Image)
+	result.ContainerName = override(a.ContainerName, b.ContainerName)
+	result.Restart = override(a.Restart, b.Restart)
+	result.User = override(a.User, b.User)
+	result.WorkingDir = override(a.WorkingDir, b.WorkingDir)
+	result.Hostname = override(a.Hostname, b.Hostname)
+	result.DomainName = override(a.DomainName, b.DomainName)
+	result.MacAddress = override(a.MacAddress, b.MacAddress)
+	result.Privileged = overridePtr(a.Privileged, b.Privileged)
+	result.ReadOnly = overridePtr(a.ReadOnly, b.ReadOnly)
+	result.StdinOpen = overridePtr(a.StdinOpen, b.StdinOpen)
+	result.Tty = overridePtr(a.Tty, b.Tty)
+	result.CPU = override(a.CPU, b.CPU)
+	result.CPUs = override(a.CPUs, b.CPUs)
+	result.CPUSet = override(a.CPUSet, b.CPUSet)
+	result.Memory = override(a.Memory, b.Memory)
+	result.MemSwap = override(a.MemSwap, b.MemSwap)
+	result.ShmSize = override(a.ShmSize, b.ShmSize)
+	result.PidMode = override(a.PidMode, b.PidMode)
+	result.IPC = override(a.IPC, b.IPC)
+	result.StopSignal = override(a.StopSignal, b.StopSignal)
+	result.StopGracePeriod = overridePtr(a.StopGracePeriod, b.StopGracePeriod)
+	result.LogDriver = override(a.LogDriver, b.LogDriver)
+
+	// Sequences that are replaced as a whole
+	if b.Command != nil {
+		result.Command = b.Command
+	}
+	if b.Entrypoint != nil {
+		result.Entrypoint = b.Entrypoint
+	}
+
+	// Mappings
+	if len(b.Environment) > 0 {
+		result.Environment = result.Environment.Merge(b.Environment)
+	}
+	result.Labels = mergeMaps(a.Labels, b.Labels)
+	result.DependsOn = mergeMaps(a.DependsOn, b.DependsOn)
+	result.LogOpt = mergeMaps(a.LogOpt, b.LogOpt)
+	result.Ulimits = mergeExtras(a.Ulimits, b.Ulimits)
+	result.Networks = mergeServiceNetworks(a.Networks, b.Networks)
+
+	// Sequences merged by key
+	if len(b.Ports) > 0 {
+		result.Ports = mergeByKey(result.Ports, b.Ports, ServicePortConfig.Key)
+	}
+	if len(b.Volumes) > 0 {
+		result.Volumes = mergeByKey(result.Volumes, b.Volumes, ServiceVolumeConfig.Key)
+	}
+	if len(b.Secrets) > 0 {
+		result.Secrets = mergeByKey(result.Secrets, b.Secrets, func(s ServiceFileReferenceConfig) string {
+			return s.Key("/run/secrets")
+		})
+	}
+	if len(b.Configs) > 0 {
+		result.Configs = mergeByKey(result.Configs, b.Configs, func(c ServiceFileReferenceConfig) string {
+			return c.Key("/")
+		})
+	}
+	if len(b.EnvFile) > 0 {
+		result.EnvFile = mergeByKey(result.EnvFile, b.EnvFile, func(f EnvFileConfig) string {
+			return f.Path
+		})
+	}
+
+	// Sequences merged as unique values
+	result.Expose = unionSlices(a.Expose, b.Expose)
+	result.VolumesFrom = unionSlices(a.VolumesFrom, b.VolumesFrom)
+	result.Links = unionSlices(a.Links, b.Links)
+	result.ExternalLinks = unionSlices(a.ExternalLinks, b.ExternalLinks)
+	result.SecurityOpt = unionSlices(a.SecurityOpt, b.SecurityOpt)
+	result.Devices = unionSlices(a.Devices, b.Devices)
+	result.ExtraHosts = unionSlices(a.ExtraHosts, b.ExtraHosts)
+	result.DNSSearch = unionSlices(a.DNSSearch, b.DNSSearch)
+	result.DNSOpt = unionSlices(a.DNSOpt, b.DNSOpt)
+	result.DNS = mergeStringOrList(a.DNS, b.DNS)
+	result.TmpFS = mergeStringOrList(a.TmpFS, b.TmpFS)
+
+	// Nested structures
+	result.Build = mergeBuild(a.Build, b.Build)
+	result.Deploy = mergeDeploy(a.Deploy, b.Deploy)
+	result.HealthCheck = mergeHealthCheck(a.HealthCheck, b.HealthCheck)
+
+	// Resolved when the file is read, so normally unset by now
+	result.Extends = overridePtr(a.Extends, b.Extends)
+
+	// Merge keys the Service struct does not model, some of their lists are unions in compose
+	result.Extras = mergeExtras(a.Extras, b.Extras)
+	for _, key := range unionExtraLists {
+		aList, aOk := scalarList(a.Extras[key])
+		bList, bOk := scalarList(b.Extras[key])
+		if aOk && bOk {
+			result.Extras[key] = unionSlices(aList, bList)
+		}
+	}
+
+	return result
+}
+
+// mergeBuild merges two build sections, the second taking precedence
+func mergeBuild(a, b *BuildConfig) *BuildConfig {
+	if a == nil || b == nil {
+		return overridePtr(a, b)
+	}
+
+	return &BuildConfig{
+		Context:    override(a.Context, b.Context),
+		Dockerfile: override(a.Dockerfile, b.Dockerfile),
+		Args:       mergeMaps(a.Args, b.Args),
+		Target:     override(a.Target, b.Target),
+		Labels:     mergeMaps(a.Labels, b.Labels),
+		CacheFrom:  unionSlices(a.CacheFrom, b.CacheFrom),
+		Network:    override(a.Network, b.Network),
+		ShmSize:    override(a.ShmSize, b.ShmSize),
+		Secrets:    unionSlices(a.Secrets, b.Secrets),
+		Extras:     mergeExtras(a.Extras, b.Extras),
+	}
+}
+
+// mergeDeploy merges two deploy sections, the second taking precedence
+func mergeDeploy(a, b *DeployConfig) *DeployConfig {
+	if a == nil || b == nil {
+		return overridePtr(a, b)
+	}
+
+	result := &DeployConfig{
+		Mode:         override(a.Mode, b.Mode),
+		Replicas:     overridePtr(a.Replicas, b.Replicas),
+		Labels:       mergeMaps(a.Labels, b.Labels),
+		EndpointMode: override(a.EndpointMode, b.EndpointMode),
+		Extras:       mergeExtras(a.Extras, b.Extras),
+	}
+
+	result.UpdateConfig = overridePtr(a.UpdateConfig, b.UpdateConfig)
+	if a.UpdateConfig != nil && b.UpdateConfig != nil {
+		result.UpdateConfig = &UpdateConfig{
+			Parallelism:     override(a.UpdateConfig.Parallelism, b.UpdateConfig.Parallelism),
+			Delay:           overridePtr(a.UpdateConfig.Delay, b.UpdateConfig.Delay),
+			FailureAction:   override(a.UpdateConfig.FailureAction, b.UpdateConfig.FailureAction),
+			Monitor:         overridePtr(a.UpdateConfig.Monitor, b.UpdateConfig.Monitor),
+			MaxFailureRatio: override(a.UpdateConfig.MaxFailureRatio, b.UpdateConfig.MaxFailureRatio),
+			Order:           override(a.UpdateConfig.Order, b.UpdateConfig.Order),
+			Extras:          mergeExtras(a.UpdateConfig.Extras, b.UpdateConfig.Extras),
+		}
+	}
+
+	result.Resources = overridePtr(a.Resources, b.Resources)
+	if a.Resources != nil && b.Resources != nil {
+		result.Resources = &ResourcesConfig{
+			Limits:       mergeResourceLimit(a.Resources.Limits, b.Resources.Limits),
+			Reservations: mergeResourceLimit(a.Resources.Reservations, b.Resources.Reservations),
+			Extras:       mergeExtras(a.Resources.Extras, b.Resources.Extras),
+		}
+	}
+
+	result.RestartPolicy = overridePtr(a.RestartPolicy, b.RestartPolicy)
+	if a.RestartPolicy != nil && b.RestartPolicy != nil {
+		result.RestartPolicy = &RestartPolicyConfig{
+			Condition:   override(a.RestartPolicy.Condition, b.RestartPolicy.Condition),
+			Delay:       overridePtr(a.RestartPolicy.Delay, b.RestartPolicy.Delay),
+			MaxAttempts: override(a.RestartPolicy.MaxAttempts, b.RestartPolicy.MaxAttempts),
+			Window:      overridePtr(a.RestartPolicy.Window, b.RestartPolicy.Window),
+			Extras:      mergeExtras(a.RestartPolicy.Extras, b.RestartPolicy.Extras),
+		}
+	}
+
+	result.Placement = overridePtr(a.Placement, b.Placement)
+	if a.Placement != nil && b.Placement != nil {
+		result.Placement = &PlacementConfig{
+			Constraints: unionSlices(a.Placement.Constraints, b.Placement.Constraints),
+			Preferences: slices.Concat(a.Placement.Preferences, b.Placement.Preferences),
+			MaxReplicas: override(a.Placement.MaxReplicas, b.Placement.MaxReplicas),
+			Extras:      mergeExtras(a.Placement.Extras, b.Placement.Extras),
+		}
+	}
+
+	return result
+}
+
+// mergeResourceLimit merges two resource limits, the second taking precedence
+func mergeResourceLimit(a, b *ResourceLimit) *ResourceLimit {
+	if a == nil || b == nil {
+		return overridePtr(a, b)
+	}
+
+	return &ResourceLimit{
+		CPUs:    override(a.CPUs, b.CPUs),
+		Memory:  override(a.Memory, b.Memory),
+		Devices: unionSlices(a.Devices, b.Devices),
+		Extras:  mergeExtras(a.Extras, b.Extras),
+	}
+}
+
+// mergeHealthCheck merges two healthchecks, the second taking precedence. The test command is replaced, not merged.
+func mergeHealthCheck(a, b *HealthCheckConfig) *HealthCheckConfig {
+	if a == nil || b == nil {
+		return overridePtr(a, b)
+	}
+
+	result := &HealthCheckConfig{
+		Test:        a.Test,
+		Interval:    overridePtr(a.Interval, b.Interval),
+		Timeout:     overridePtr(a.Timeout, b.Timeout),
+		Retries:     overridePtr(a.Retries, b.Retries),
+		StartPeriod: overridePtr(a.StartPeriod, b.StartPeriod),
+		Disable:     overridePtr(a.Disable, b.Disable),
+		Extras:      mergeExtras(a.Extras, b.Extras),
+	}
+	if b.Test != nil {
+		result.Test = b.Test
+	}
+
+	return result
+}
+
+// mergeServiceNetworks merges a service's networks, which may each be a list of names or a map of name to config.
+// Two lists give a list, otherwise the result is a map with list entries given an empty config.
+func mergeServiceNetworks(a, b interface{}) interface{} {
+	if a == nil || b == nil {
+		if b != nil {
+			return b
+		}
+		return a
+	}
+
+	aList, aIsList := a.([]interface{})
+	bList, bIsList := b.([]interface{})
+	if aIsList && bIsList {
+		return unionSlices(aList, bList)
+	}
+
+	aMap := networksToMap(a)
+	bMap := networksToMap(b)
+	for name, config := range bMap {
+		// A network listed without config joins it, it does not clear the config given in a
+		if _, inA := aMap[name]; inA && config == nil {
+			delete(bMap, name)
+		}
+	}
+	return mergeExtras(aMap, bMap)
+}
+
+// networksToMap converts the list form of a service's networks to the map form
+func networksToMap(networks interface{}) map[string]interface{} {
+	switch n := networks.(type) {
+	case map[string]interface{}:
+		return maps.Clone(n)
+	case []interface{}:
+		result := make(map[string]interface{}, len(n))
+		for _, name := range n {
+			if s, ok := name.(string); ok {
+				result[s] = nil
+			}
+		}
+		return result
+	}
+	return nil
+}
+
+// mergeStringOrList merges fields that may be a single string or a list of strings, giving a list of unique values.
+// Any other shape in b replaces a.
+func mergeStringOrList(a, b interface{}) interface{} {
+	if b == nil {
+		return a
+	}
+	if a == nil {
+		return b
+	}
+
+	aList, aOk := stringOrList(a)
+	bList, bOk := stringOrList(b)
+	if !aOk || !bOk {
+		return b
+	}
+
+	return unionSlices(aList, bList)
+}
+
+func stringOrList(v interface{}) ([]interface{}, bool) {
+	switch value := v.(type) {
+	case string:
+		return []interface{}{value}, true
+	case []interface{}:
+		for _, item := range value {
+			if _, ok := item.(string); !ok {
+				return nil, false
+			}
+		}
+		return value, true
+	}
+	return nil, false
+}
+
+// scalarList returns v as a list if it is one holding only scalar values, which can be compared for a union
+func scalarList(v interface{}) ([]interface{}, bool) {
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil, false
+	}
+	for _, item := range list {
+		switch item.(type) {
+		case map[string]interface{}, []interface{}:
+			return nil, false
+		}
+	}
+	return list, true
+}
+
+// mergeExtras merges two sets of unmodelled keys, mappings are merged recursively and
+// any other value in b replaces the value in a
+func mergeExtras(a, b map[string]interface{}) map[string]interface{} {
+	if a == nil && b == nil {
+		return nil
+	}
+
+	result := make(map[string]interface{}, len(a)+len(b))
+	maps.Copy(result, a)
+	for key, bValue := range b {
+		aMap, aIsMap := result[key].(map[string]interface{})
+		bMap, bIsMap := bValue.(map[string]interface{})
+		if aIsMap && bIsMap {
+			result[key] = mergeExtras(aMap, bMap)
+			continue
+		}
+		result[key] = bValue
+	}
+
+	return result
+}
+
+// override returns b if it is set, otherwise a
+func override[T comparable](a, b T) T {
+	var zero T
+	if b != zero {
+		return b
+	}
+	return a
+}
+
+// overridePtr returns b if it is set, otherwise a
+func overridePtr[T any](a, b *T) *T {
+	if b != nil {
+		return b
+	}
+	return a
+}
+
+// mergeMaps returns a copy of a with every key of b added, b taking precedence
+func mergeMaps[M ~map[K]V, K comparable, V any](a, b M) M {
+	if a == nil && b == nil {
+		return nil
+	}
+	result := make(M, len(a)+len(b))
+	maps.Copy(result, a)
+	maps.Copy(result, b)
+	return result
+}
+
+// unionSlices returns the values of a followed by those of b not already present
+func unionSlices[T comparable](a, b []T) []T {
+	if a == nil && b == nil {
+		return nil
+	}
+	result := make([]T, 0, len(a)+len(b))
+	for _, v := range slices.Concat(a, b) {
+		if !slices.Contains(result, v) {
+			result = append(result, v)
+		}
+	}
+	return result
+}
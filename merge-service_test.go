@@ -0,0 +1,700 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// mergeServiceCases covers every field of Service, each case names the compose keys it exercises
+var mergeServiceCases = []struct {
+	name string
+	a    string
+	b    string
+	want string
+}{
+	{
+		name: "scalars are overridden when set",
+		a: `
+image: app:1
+container_name: app
+restart: always
+user: "1000"
+working_dir: /app
+hostname: app
+domainname: example.com
+mac_address: 02:42:ac:11:00:02
+cpu_shares: 512
+cpus: "0.5"
+cpuset: "0"
+mem_limit: 256m
+memswap_limit: 512m
+shm_size: 64m
+pid: host
+ipc: host
+stop_signal: SIGTERM
+stop_grace_period: 10s
+log_driver: json-file
+`,
+		b: `
+image: app:2
+container_name: app-2
+restart: unless-stopped
+user: "1001"
+working_dir: /srv
+hostname: app2
+domainname: example.org
+mac_address: 02:42:ac:11:00:03
+cpu_shares: 1024
+cpus: "1"
+cpuset: "1"
+mem_limit: 512m
+memswap_limit: 1g
+shm_size: 128m
+pid: service:db
+ipc: shareable
+stop_signal: SIGINT
+stop_grace_period: 20s
+log_driver: syslog
+`,
+		want: `
+image: app:2
+container_name: app-2
+restart: unless-stopped
+user: "1001"
+working_dir: /srv
+hostname: app2
+domainname: example.org
+mac_address: 02:42:ac:11:00:03
+cpu_shares: 1024
+cpus: "1"
+cpuset: "1"
+mem_limit: 512m
+memswap_limit: 1g
+shm_size: 128m
+pid: service:db
+ipc: shareable
+stop_signal: SIGINT
+stop_grace_period: 20s
+log_driver: syslog
+`,
+	},
+	{
+		name: "scalars are kept when unset",
+		a: `
+image: app:1
+restart: always
+stop_grace_period: 10s
+`,
+		b: `
+user: "1000"
+`,
+		want: `
+image: app:1
+restart: always
+stop_grace_period: 10s
+user: "1000"
+`,
+	},
+	{
+		name: "pointer bools override only when set, including to false",
+		a: `
+privileged: true
+read_only: true
+stdin_open: true
+tty: false
+`,
+		b: `
+privileged: false
+tty: true
+`,
+		want: `
+privileged: false
+read_only: true
+stdin_open: true
+tty: true
+`,
+	},
+	{
+		name: "command and entrypoint are replaced",
+		a: `
+command: ["npm", "start"]
+entrypoint: /docker-entrypoint.sh
+`,
+		b: `
+command: npm run dev
+entrypoint: ["/bin/sh", "-c"]
+`,
+		want: `
+command: npm run dev
+entrypoint: ["/bin/sh", "-c"]
+`,
+	},
+	{
+		name: "environment merges list and map forms",
+		a: `
+environment:
+  - DEBUG=false
+  - HOME
+  - PORT=80
+`,
+		b: `
+environment:
+  DEBUG: "true"
+  EXTRA: x
+`,
+		want: `
+environment:
+  DEBUG: "true"
+  EXTRA: x
+  HOME:
+  PORT: "80"
+`,
+	},
+	{
+		name: "mappings are merged key by key",
+		a: `
+labels:
+  tier: web
+  team: a
+log_opt:
+  max-size: 10m
+ulimits:
+  nofile:
+    soft: 1024
+    hard: 2048
+  nproc: 65535
+depends_on:
+  - db
+`,
+		b: `
+labels:
+  team: b
+log_opt:
+  max-file: "3"
+ulimits:
+  nofile:
+    soft: 4096
+depends_on:
+  cache:
+    condition: service_healthy
+`,
+		want: `
+labels:
+  tier: web
+  team: b
+log_opt:
+  max-size: 10m
+  max-file: "3"
+ulimits:
+  nofile:
+    soft: 4096
+    hard: 2048
+  nproc: 65535
+depends_on:
+  db:
+    condition: service_started
+  cache:
+    condition: service_healthy
+`,
+	},
+	{
+		name: "networks as two lists give a list",
+		a: `
+networks: [front, back]
+`,
+		b: `
+networks: [back, admin]
+`,
+		want: `
+networks: [front, back, admin]
+`,
+	},
+	{
+		name: "networks listed over a map keep their config",
+		a: `
+networks:
+  front:
+    aliases: [web]
+  back:
+`,
+		b: `
+networks: [front, admin]
+`,
+		want: `
+networks:
+  front:
+    aliases: [web]
+  back:
+  admin:
+`,
+	},
+	{
+		name: "networks as a map over a list add config",
+		a: `
+networks: [front]
+`,
+		b: `
+networks:
+  front:
+    ipv4_address: 172.16.0.10
+`,
+		want: `
+networks:
+  front:
+    ipv4_address: 172.16.0.10
+`,
+	},
+	{
+		name: "keyed sequences replace entries sharing a key",
+		a: `
+ports:
+  - "8080:80"
+  - "127.0.0.1:443:443"
+  - "[::1]:443:443"
+volumes:
+  - ./src:/app/src
+  - data:/data
+secrets:
+  - token
+  - source: cert
+    target: /certs/cert.pem
+configs:
+  - app
+env_file:
+  - .env
+  - path: .env.local
+    required: false
+`,
+		b: `
+ports:
+  - "9090:80"
+  - target: 53
+    protocol: udp
+volumes:
+  - ./lib:/app/src:ro
+secrets:
+  - source: token
+    mode: 0400
+configs:
+  - source: app
+    target: /app.conf
+env_file:
+  - path: .env.local
+    required: true
+  - .env.prod
+`,
+		want: `
+ports:
+  - "9090:80"
+  - "127.0.0.1:443:443"
+  - "[::1]:443:443"
+  - target: 53
+    protocol: udp
+volumes:
+  - ./lib:/app/src:ro
+  - data:/data
+secrets:
+  - source: token
+    mode: 0400
+  - source: cert
+    target: /certs/cert.pem
+configs:
+  - app
+  - source: app
+    target: /app.conf
+env_file:
+  - .env
+  - path: .env.local
+    required: true
+  - .env.prod
+`,
+	},
+	{
+		name: "entries within one file are not collapsed",
+		a: `
+ports:
+  - "5432:5432"
+`,
+		b: `
+ports:
+  - "127.0.0.1:5432:5432"
+  - "[::1]:5432:5432"
+  - "15432:5432"
+`,
+		want: `
+ports:
+  - "127.0.0.1:5432:5432"
+  - "[::1]:5432:5432"
+  - "15432:5432"
+`,
+	},
+	{
+		name: "set like sequences are unions",
+		a: `
+expose: ["80"]
+volumes_from: [data]
+links: [db]
+external_links: [redis_1]
+security_opt: [no-new-privileges:true]
+devices: [/dev/fuse]
+extra_hosts: [host.docker.internal:host-gateway]
+dns_search: [example.com]
+dns_opt: [use-vc]
+dns: 8.8.8.8
+tmpfs: /run
+`,
+		b: `
+expose: ["80", "443"]
+volumes_from: [logs]
+links: [db, cache]
+external_links: [redis_1]
+security_opt: [seccomp:unconfined]
+devices: [/dev/fuse, /dev/kvm]
+extra_hosts: [db.local:10.0.0.2]
+dns_search: [example.org]
+dns_opt: [rotate]
+dns: [1.1.1.1]
+tmpfs: [/run, /tmp]
+`,
+		want: `
+expose: ["80", "443"]
+volumes_from: [data, logs]
+links: [db, cache]
+external_links: [redis_1]
+security_opt: [no-new-privileges:true, seccomp:unconfined]
+devices: [/dev/fuse, /dev/kvm]
+extra_hosts: [host.docker.internal:host-gateway, db.local:10.0.0.2]
+dns_search: [example.com, example.org]
+dns_opt: [use-vc, rotate]
+dns: [8.8.8.8, 1.1.1.1]
+tmpfs: [/run, /tmp]
+`,
+	},
+	{
+		name: "build is merged field by field, keeping unknown keys",
+		a: `
+build:
+  context: ./app
+  dockerfile: Dockerfile
+  args:
+    A: "1"
+  labels:
+    x: "1"
+  cache_from: [app:cache]
+  secrets: [npmrc]
+  tags: [app:1]
+`,
+		b: `
+build:
+  target: dev
+  network: host
+  shm_size: 256m
+  args:
+    B: "2"
+  cache_from: [app:dev-cache]
+  platforms: [linux/amd64]
+`,
+		want: `
+build:
+  context: ./app
+  dockerfile: Dockerfile
+  target: dev
+  network: host
+  shm_size: 256m
+  args:
+    A: "1"
+    B: "2"
+  labels:
+    x: "1"
+  cache_from: [app:cache, app:dev-cache]
+  secrets: [npmrc]
+  tags: [app:1]
+  platforms: [linux/amd64]
+`,
+	},
+	{
+		name: "deploy is merged field by field, keeping unknown keys",
+		a: `
+deploy:
+  mode: replicated
+  replicas: 1
+  labels:
+    a: "1"
+  update_config:
+    parallelism: 1
+    order: stop-first
+  resources:
+    limits:
+      cpus: "0.5"
+      memory: 256M
+      pids: 100
+  restart_policy:
+    condition: on-failure
+    max_attempts: 3
+  placement:
+    constraints: [node.role == worker]
+  rollback_config:
+    parallelism: 1
+`,
+		b: `
+deploy:
+  replicas: 3
+  endpoint_mode: dnsrr
+  update_config:
+    order: start-first
+  resources:
+    limits:
+      memory: 512M
+    reservations:
+      memory: 128M
+  restart_policy:
+    delay: 5s
+  placement:
+    constraints: [node.labels.ssd == true]
+    max_replicas_per_node: 2
+`,
+		want: `
+deploy:
+  mode: replicated
+  replicas: 3
+  endpoint_mode: dnsrr
+  labels:
+    a: "1"
+  update_config:
+    parallelism: 1
+    order: start-first
+  resources:
+    limits:
+      cpus: "0.5"
+      memory: 512M
+      pids: 100
+    reservations:
+      memory: 128M
+  restart_policy:
+    condition: on-failure
+    delay: 5s
+    max_attempts: 3
+  placement:
+    constraints: [node.role == worker, node.labels.ssd == true]
+    max_replicas_per_node: 2
+  rollback_config:
+    parallelism: 1
+`,
+	},
+	{
+		name: "healthcheck is merged with the test replaced",
+		a: `
+healthcheck:
+  test: ["CMD", "curl", "-f", "http://localhost"]
+  interval: 30s
+  retries: 3
+  start_interval: 5s
+`,
+		b: `
+healthcheck:
+  test: ["CMD-SHELL", "true"]
+  timeout: 5s
+  start_period: 10s
+`,
+		want: `
+healthcheck:
+  test: ["CMD-SHELL", "true"]
+  interval: 30s
+  timeout: 5s
+  retries: 3
+  start_period: 10s
+  start_interval: 5s
+`,
+	},
+	{
+		name: "healthcheck disable and retries override only when set, including to false and 0",
+		a: `
+healthcheck:
+  disable: true
+  retries: 3
+`,
+		b: `
+healthcheck:
+  disable: false
+  retries: 0
+`,
+		want: `
+healthcheck:
+  disable: false
+  retries: 0
+`,
+	},
+	{
+		name: "healthcheck disable is kept when unset",
+		a: `
+healthcheck:
+  disable: true
+`,
+		b: `
+healthcheck:
+  retries: 1
+`,
+		want: `
+healthcheck:
+  disable: true
+  retries: 1
+`,
+	},
+	{
+		name: "deploy replicas can be overridden to 0",
+		a: `
+deploy:
+  replicas: 3
+`,
+		b: `
+deploy:
+  replicas: 0
+`,
+		want: `
+deploy:
+  replicas: 0
+`,
+	},
+	{
+		name: "depends_on restart false is kept",
+		a: `
+depends_on:
+  db:
+    condition: service_healthy
+    restart: true
+`,
+		b: `
+depends_on:
+  db:
+    condition: service_healthy
+    restart: false
+`,
+		want: `
+depends_on:
+  db:
+    condition: service_healthy
+    restart: false
+`,
+	},
+	{
+		name: "unmodelled keys are deep merged, their set like lists are unions",
+		a: `
+platform: linux/amd64
+cap_add: [NET_ADMIN]
+cap_drop: [ALL]
+group_add: [audio]
+profiles: [dev]
+x-meta:
+  owner: a
+  tags: [one]
+`,
+		b: `
+platform: linux/arm64
+cap_add: [SYS_TIME, NET_ADMIN]
+cap_drop: [MKNOD]
+group_add: [video, 1000]
+profiles: [test]
+x-meta:
+  tags: [two]
+`,
+		want: `
+platform: linux/arm64
+cap_add: [NET_ADMIN, SYS_TIME]
+cap_drop: [ALL, MKNOD]
+group_add: [audio, video, 1000]
+profiles: [dev, test]
+x-meta:
+  owner: a
+  tags: [two]
+`,
+	},
+	{
+		name: "extends is overridden when set",
+		a: `
+extends: base
+`,
+		b: `
+extends:
+  file: common.yml
+  service: app
+`,
+		want: `
+extends:
+  file: common.yml
+  service: app
+`,
+	},
+}
+
+func TestMergeServices(t *testing.T) {
+	dc := &DockerComposeCompiler{Config: &Options{}}
+
+	for _, tc := range mergeServiceCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := dc.mergeServices(parseService(t, tc.a), parseService(t, tc.b))
+
+			gotYAML := genericService(t, got)
+			wantYAML := genericService(t, parseService(t, tc.want))
+			if !reflect.DeepEqual(gotYAML, wantYAML) {
+				gotText, _ := yaml.Marshal(gotYAML)
+				wantText, _ := yaml.Marshal(wantYAML)
+				t.Errorf("merged service mismatch\ngot:\n%s\nwant:\n%s", gotText, wantText)
+			}
+		})
+	}
+}
+
+// TestMergeServicesCoversEveryField fails when a Service field is not exercised by any case above
+func TestMergeServicesCoversEveryField(t *testing.T) {
+	covered := map[string]bool{}
+	for _, tc := range mergeServiceCases {
+		for _, doc := range []string{tc.a, tc.b} {
+			var keys map[string]interface{}
+			if err := yaml.Unmarshal([]byte(doc), &keys); err != nil {
+				t.Fatalf("%s: %v", tc.name, err)
+			}
+			for key := range keys {
+				covered[key] = true
+			}
+		}
+	}
+
+	serviceType := reflect.TypeOf(Service{})
+	for i := 0; i < serviceType.NumField(); i++ {
+		field := serviceType.Field(i)
+		tag, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if tag == "" {
+			// Extras, covered by the unmodelled keys case
+			continue
+		}
+		if !covered[tag] {
+			t.Errorf("no merge case covers Service.%s (%s)", field.Name, tag)
+		}
+	}
+}
+
+func parseService(t *testing.T, doc string) Service {
+	t.Helper()
+	var service Service
+	if err := yaml.Unmarshal([]byte(doc), &service); err != nil {
+		t.Fatalf("error parsing service: %v\n%s", err, doc)
+	}
+	return service
+}
+
+// genericService converts a service to plain maps and slices, as it would be written and read back
+func genericService(t *testing.T, service Service) map[string]interface{} {
+	t.Helper()
+	data, err := yaml.Marshal(service)
+	if err != nil {
+		t.Fatalf("error marshaling service: %v", err)
+	}
+	var generic map[string]interface{}
+	if err := yaml.Unmarshal(data, &generic); err != nil {
+		t.Fatalf("error reading back service: %v", err)
+	}
+	return generic
+}
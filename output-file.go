@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultOutputDir      = "{{.ProjectPath}}/{{.Output}}/docker"
+	defaultOutputFileName = "docker-compose.yml"
+	defaultSecretsDir     = "{{.ProjectPath}}/{{.Output}}/config"
+)
+
+// OutputTemplateData is the data available to the output location templates in Options
+type OutputTemplateData struct {
+	ProjectPath   string // Options.ProjectPath
+	ProjectFolder string // Options.ProjectFolder
+	Output        string // Options.Output
+	BaseDir       string // directory containing the base compose file
+}
+
+// resolveOutputPaths renders the output location templates and sets OutputPath and SecretsDir
+func (dc *DockerComposeCompiler) resolveOutputPaths() error {
+	outputDir := dc.Config.OutputDir
+	if outputDir == "" {
+		if dc.Config.Output == "" {
+			return fmt.Errorf("output path not set")
+		}
+		outputDir = defaultOutputDir
+	}
+	fileName := dc.Config.OutputFileName
+	if fileName == "" {
+		fileName = defaultOutputFileName
+	}
+	secretsDir := dc.Config.SecretsDir
+	if secretsDir == "" {
+		secretsDir = defaultSecretsDir
+	}
+
+	data := OutputTemplateData{
+		ProjectPath:   dc.Config.ProjectPath,
+		ProjectFolder: dc.Config.ProjectFolder,
+		Output:        dc.Config.Output,
+		BaseDir:       filepath.Dir(dc.resolvePath(dc.Config.BasePath)),
+	}
+
+	dir, err := renderPathTemplate("outputDir", outputDir, data)
+	if err != nil {
+		return err
+	}
+	name, err := renderPathTemplate("outputFileName", fileName, data)
+	if err != nil {
+		return err
+	}
+	secrets, err := renderPathTemplate("secretsDir", secretsDir, data)
+	if err != nil {
+		return err
+	}
+
+	dc.OutputPath = filepath.Join(dc.resolvePath(dir), name)
+	dc.SecretsDir = dc.resolvePath(secrets)
+	return nil
+}
+
+// renderPathTemplate executes a single path template against data
+func renderPathTemplate(name, text string, data OutputTemplateData) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s template %q: %w", name, text, err)
+	}
+
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, data)
+	if err != nil {
+		return "", fmt.Errorf("error rendering %s template %q: %w", name, text, err)
+	}
+
+	return filepath.Clean(buf.String()), nil
+}
+
+// writeFileAtomic writes data to a temp file next to path and renames it into place,
+// creating any missing parent directories, so readers never see a partially written file
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	// Clean up the temp file if anything below fails, after a successful rename this is a no-op
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	err = tmp.Chmod(perm)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// topLevelKeyOrder is the order of the top level keys in the output, following the compose-spec.
+// Other keys (x- extensions, ...) follow in alphabetical order.
+var topLevelKeyOrder = []string{"version", "name", "include", "services", "networks", "volumes", "secrets", "configs"}
+
+// unorderedServiceLists are the service keys whose lists have no meaningful order, they are sorted in the output.
+// Lists such as command, entrypoint, env_file and the dns settings (resolver priority) are order sensitive and kept as merged.
+var unorderedServiceLists = map[string]bool{
+	"cap_add": true, "cap_drop": true, "configs": true, "devices": true, "expose": true,
+	"external_links": true, "extra_hosts": true, "group_add": true, "links": true, "networks": true,
+	"ports": true, "profiles": true, "secrets": true, "security_opt": true, "tmpfs": true,
+	"volumes": true, "volumes_from": true,
+}
+
+// canonicalize puts an encoded compose document into the canonical layout: top level keys in compose-spec order,
+// every other mapping sorted by key and the unordered lists of each service sorted.
+// With extensionsFirst the other top level keys come before services, so anchors defined in them precede their aliases.
+func canonicalize(root *yaml.Node, extensionsFirst bool) {
+	doc := root
+	if doc.Kind == yaml.DocumentNode && len(doc.Content) == 1 {
+		doc = doc.Content[0]
+	}
+	if doc.Kind != yaml.MappingNode {
+		return
+	}
+
+	rank := func(key string) int {
+		i := slices.Index(topLevelKeyOrder, key)
+		switch {
+		case i < 0 && extensionsFirst:
+			return slices.Index(topLevelKeyOrder, "services")
+		case i < 0:
+			return len(topLevelKeyOrder)
+		case extensionsFirst && i >= slices.Index(topLevelKeyOrder, "services"):
+			return i + 1
+		}
+		return i
+	}
+	sortMapping(doc, func(a, b string) int {
+		if c := cmp.Compare(rank(a), rank(b)); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	})
+
+	for i := 0; i < len(doc.Content); i += 2 {
+		value := doc.Content[i+1]
+		sortMappingsDeep(value)
+		if doc.Content[i].Value != "services" || value.Kind != yaml.MappingNode {
+			continue
+		}
+		for j := 1; j < len(value.Content); j += 2 {
+			sortServiceLists(value.Content[j])
+		}
+	}
+}
+
+// sortMapping reorders the key/value pairs of a mapping node by key
+func sortMapping(node *yaml.Node, compare func(a, b string) int) {
+	pairs := make([][2]*yaml.Node, 0, len(node.Content)/2)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		pairs = append(pairs, [2]*yaml.Node{node.Content[i], node.Content[i+1]})
+	}
+	slices.SortStableFunc(pairs, func(a, b [2]*yaml.Node) int {
+		return compare(a[0].Value, b[0].Value)
+	})
+
+	node.Content = node.Content[:0]
+	for _, pair := range pairs {
+		node.Content = append(node.Content, pair[0], pair[1])
+	}
+}
+
+// sortMappingsDeep sorts every mapping at or below node by key
+func sortMappingsDeep(node *yaml.Node) {
+	if node.Kind == yaml.MappingNode {
+		sortMapping(node, strings.Compare)
+	}
+	for _, child := range node.Content {
+		sortMappingsDeep(child)
+	}
+}
+
+// sortServiceLists sorts the unordered lists of a service node, entries are compared by their YAML text
+func sortServiceLists(service *yaml.Node) {
+	if service.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(service.Content); i += 2 {
+		list := service.Content[i+1]
+		if !unorderedServiceLists[service.Content[i].Value] || list.Kind != yaml.SequenceNode {
+			continue
+		}
+		slices.SortStableFunc(list.Content, func(a, b *yaml.Node) int {
+			return strings.Compare(nodeText(a), nodeText(b))
+		})
+	}
+}
+
+// nodeText is the YAML text of a node, a sort key for list entries of any shape
+func nodeText(node *yaml.Node) string {
+	if node.Kind == yaml.ScalarNode {
+		return node.Value
+	}
+	text, err := yaml.Marshal(node)
+	if err != nil {
+		return ""
+	}
+	return string(text)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// parseErrorContextLines is the number of lines shown either side of the offending line in a ParseError snippet
+const parseErrorContextLines = 2
+
+// ParseError is returned when a compose file is not valid YAML or does not match the compose structure.
+// It points at the offending position so the broken file can be found among many projects.
+type ParseError struct {
+	File    string
+	Line    int // 1 based, 0 when yaml.v3 did not report a position
+	Column  int // 1 based, 0 when unknown
+	Snippet string
+	Err     error
+}
+
+func (e *ParseError) Error() string {
+	msg := strings.TrimPrefix(e.Err.Error(), "yaml: ")
+	if e.Line == 0 {
+		return fmt.Sprintf("%s: %s", e.File, msg)
+	}
+	msg = strings.TrimPrefix(msg, fmt.Sprintf("line %d: ", e.Line))
+	if e.Column == 0 {
+		return fmt.Sprintf("%s:%d: %s\n%s", e.File, e.Line, msg, e.Snippet)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s\n%s", e.File, e.Line, e.Column, msg, e.Snippet)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+var yamlErrorLine = regexp.MustCompile(`line (\d+)`)
+
+// newParseError positions a yaml.v3 error within data. yaml.v3 only reports lines in its messages,
+// so the column is taken from the first node of root on that line when the document could be parsed.
+func newParseError(file string, data []byte, root *yaml.Node, err error) *ParseError {
+	parseErr := &ParseError{File: file, Err: err}
+
+	m := yamlErrorLine.FindStringSubmatch(err.Error())
+	if m == nil {
+		return parseErr
+	}
+	parseErr.Line, _ = strconv.Atoi(m[1])
+	if node := firstNodeOnLine(root, parseErr.Line); node != nil {
+		parseErr.Column = node.Column
+	}
+	parseErr.Snippet = snippet(data, parseErr.Line, parseErr.Column)
+
+	return parseErr
+}
+
+// newNodeError positions an error found while processing node, e.g. a failed variable substitution
+func newNodeError(file string, data []byte, node *yaml.Node, err error) *ParseError {
+	return &ParseError{
+		File:    file,
+		Line:    node.Line,
+		Column:  node.Column,
+		Snippet: snippet(data, node.Line, node.Column),
+		Err:     err,
+	}
+}
+
+// firstNodeOnLine searches root depth first for the first node starting on line
+func firstNodeOnLine(root *yaml.Node, line int) *yaml.Node {
+	if root == nil {
+		return nil
+	}
+	if root.Line == line && root.Kind != yaml.DocumentNode {
+		return root
+	}
+	for _, child := range root.Content {
+		if node := firstNodeOnLine(child, line); node != nil {
+			return node
+		}
+	}
+	return nil
+}
+
+// snippet returns the lines around line, numbered, with the offending line marked and a caret under column
+// when it is known
+func snippet(data []byte, line, column int) string {
+	lines := strings.Split(string(data), "\n")
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+
+	var out strings.Builder
+	width := len(strconv.Itoa(min(line+parseErrorContextLines, len(lines))))
+	for n := max(line-parseErrorContextLines, 1); n <= min(line+parseErrorContextLines, len(lines)); n++ {
+		marker := " "
+		if n == line {
+			marker = ">"
+		}
+		fmt.Fprintf(&out, "%s %*d | %s\n", marker, width, n, lines[n-1])
+		if n == line && column > 0 {
+			fmt.Fprintf(&out, "  %s | %s^\n", strings.Repeat(" ", width), strings.Repeat(" ", column-1))
+		}
+	}
+
+	return strings.TrimSuffix(out.String(), "\n")
+}
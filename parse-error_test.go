@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestNewParseError(t *testing.T) {
+	cases := []struct {
+		name       string
+		doc        string
+		decode     bool // decode into DockerCompose, giving a structure error on a parsed document
+		wantLine   int
+		wantColumn int
+		wantError  string
+	}{
+		{
+			name:      "syntax error has a line but no column",
+			doc:       "services:\n  api:\n    image: a: b\n",
+			wantLine:  3,
+			wantError: "compose.yml:3: mapping values are not allowed in this context\n  1 | services:\n  2 |   api:\n> 3 |     image: a: b\n  4 | ",
+		},
+		{
+			name:       "structure error takes the column from the first node on its line",
+			doc:        "services:\n  api:\n    ports: {a: b}\n",
+			decode:     true,
+			wantLine:   3,
+			wantColumn: 5,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var root yaml.Node
+			err := yaml.Unmarshal([]byte(tc.doc), &root)
+			var parsed *yaml.Node
+			if tc.decode {
+				if err != nil {
+					t.Fatalf("unexpected syntax error: %v", err)
+				}
+				var compose DockerCompose
+				err = root.Decode(&compose)
+				parsed = &root
+			}
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+
+			parseErr := newParseError("compose.yml", []byte(tc.doc), parsed, err)
+			if parseErr.Line != tc.wantLine || parseErr.Column != tc.wantColumn {
+				t.Errorf("got position %d:%d, want %d:%d", parseErr.Line, parseErr.Column, tc.wantLine, tc.wantColumn)
+			}
+			if tc.wantError != "" && parseErr.Error() != tc.wantError {
+				t.Errorf("got error\n%s\nwant\n%s", parseErr.Error(), tc.wantError)
+			}
+			if !errors.Is(parseErr, err) {
+				t.Error("parse error does not wrap the yaml error")
+			}
+		})
+	}
+}
+
+func TestNewParseErrorWithoutPosition(t *testing.T) {
+	parseErr := newParseError("compose.yml", nil, nil, errors.New("yaml: input is empty"))
+	if parseErr.Line != 0 || parseErr.Column != 0 || parseErr.Snippet != "" {
+		t.Errorf("got position %d:%d and snippet %q, want none", parseErr.Line, parseErr.Column, parseErr.Snippet)
+	}
+	if got, want := parseErr.Error(), "compose.yml: input is empty"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	data := []byte("a: 1\nb: 2\nc: 3\nd: 4\ne: 5\nf: 6")
+	cases := []struct {
+		name   string
+		line   int
+		column int
+		want   string
+	}{
+		{
+			name:   "context on both sides with a caret",
+			line:   3,
+			column: 4,
+			want:   "  1 | a: 1\n  2 | b: 2\n> 3 | c: 3\n    |    ^\n  4 | d: 4\n  5 | e: 5",
+		},
+		{
+			name: "no caret when the column is unknown",
+			line: 3,
+			want: "  1 | a: 1\n  2 | b: 2\n> 3 | c: 3\n  4 | d: 4\n  5 | e: 5",
+		},
+		{
+			name:   "context clipped at the start",
+			line:   1,
+			column: 1,
+			want:   "> 1 | a: 1\n    | ^\n  2 | b: 2\n  3 | c: 3",
+		},
+		{
+			name: "context clipped at the end",
+			line: 6,
+			want: "  4 | d: 4\n  5 | e: 5\n> 6 | f: 6",
+		},
+		{
+			name: "line out of range",
+			line: 7,
+			want: "",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := snippet(data, tc.line, tc.column); got != tc.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tc.want)
+			}
+		})
+	}
+}
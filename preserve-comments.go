@@ -0,0 +1,281 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// The merge itself works on the DockerCompose structs, which keep no comments, anchors or quoting.
+// decorate carries them over afterwards: each source file is parsed again as a node tree and walked
+// alongside the rendered output, copying what belongs to nodes present in both.
+
+// anchorRestorer tracks the anchors and aliases found while decorating, to be written back once every source is walked
+type anchorRestorer struct {
+	anchors map[string]*yaml.Node // output nodes given back their anchor, by name
+	aliases []pendingAlias
+	merges  []pendingMerge
+}
+
+// pendingAlias is an output node that was an alias in a source file
+type pendingAlias struct {
+	parent *yaml.Node
+	index  int
+	name   string
+}
+
+// pendingMerge is an output mapping that used merge keys (<<: *name) in a source file
+type pendingMerge struct {
+	mapping *yaml.Node
+	names   []string
+}
+
+// decorate copies comments, quoting styles and, with Config.KeepAnchors, anchors from the source files
+// onto the rendered output root. Earlier sources win when several have a comment for the same node.
+func (dc *DockerComposeCompiler) decorate(root *yaml.Node) error {
+	doc := root
+	if doc.Kind == yaml.DocumentNode && len(doc.Content) == 1 {
+		doc = doc.Content[0]
+	}
+	restorer := &anchorRestorer{anchors: map[string]*yaml.Node{}}
+
+	var sourceComments []string
+	for _, source := range dc.Sources {
+		data, err := os.ReadFile(source)
+		if err != nil {
+			return fmt.Errorf("error reading source for comments:\n\tpath:%s\n\terror:%w", source, err)
+		}
+		var sourceRoot yaml.Node
+		err = yaml.Unmarshal(data, &sourceRoot)
+		if err != nil {
+			return newParseError(source, data, nil, err)
+		}
+		if sourceRoot.Kind != yaml.DocumentNode || len(sourceRoot.Content) == 0 {
+			continue
+		}
+
+		if dc.Config.PreserveComments {
+			if comment := fileComment(&sourceRoot); comment != "" {
+				sourceComments = append(sourceComments, comment)
+			}
+		}
+		dc.decorateNode(sourceRoot.Content[0], doc, nil, 0, restorer)
+	}
+
+	if dc.Config.KeepAnchors {
+		restorer.restore()
+		expandForwardAliases(root, map[string]bool{})
+	}
+
+	if dc.Config.PreserveComments {
+		// The sources themselves are listed in the provenance header written above this
+		root.HeadComment = strings.Join(sourceComments, "\n\n")
+	}
+
+	return nil
+}
+
+// decorateNode walks src and out together, out being the output node at the same position as src.
+// parent and index locate out, so it can later be replaced by an alias.
+func (dc *DockerComposeCompiler) decorateNode(src, out, parent *yaml.Node, index int, restorer *anchorRestorer) {
+	if src.Kind == yaml.AliasNode {
+		if dc.Config.KeepAnchors && parent != nil {
+			restorer.aliases = append(restorer.aliases, pendingAlias{parent: parent, index: index, name: src.Value})
+		}
+		return
+	}
+
+	if dc.Config.PreserveComments {
+		copyComments(src, out)
+	}
+	if dc.Config.KeepAnchors && src.Anchor != "" && restorer.anchors[src.Anchor] == nil && sameValue(src, out) {
+		out.Anchor = src.Anchor
+		restorer.anchors[src.Anchor] = out
+	}
+
+	switch {
+	case src.Kind == yaml.MappingNode && out.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(src.Content); i += 2 {
+			key, value := src.Content[i], src.Content[i+1]
+			if key.Value == "<<" {
+				if dc.Config.KeepAnchors {
+					restorer.merges = append(restorer.merges, pendingMerge{mapping: out, names: mergeAliasNames(value)})
+				}
+				continue
+			}
+			j := mappingIndex(out, key.Value)
+			if j < 0 {
+				continue
+			}
+			if dc.Config.PreserveComments {
+				copyComments(key, out.Content[j])
+			}
+			dc.decorateNode(value, out.Content[j+1], out, j+1, restorer)
+		}
+	case src.Kind == yaml.SequenceNode && out.Kind == yaml.SequenceNode:
+		// Entries are merged and sorted, so only scalars can be matched, by value
+		for _, item := range src.Content {
+			if item.Kind != yaml.ScalarNode {
+				continue
+			}
+			for j, outItem := range out.Content {
+				if outItem.Kind == yaml.ScalarNode && outItem.Value == item.Value {
+					dc.decorateNode(item, outItem, out, j, restorer)
+					break
+				}
+			}
+		}
+	case src.Kind == yaml.ScalarNode && out.Kind == yaml.ScalarNode:
+		// Keep quoting and block styles for strings only, a quoted number would change type
+		styled := src.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle|yaml.LiteralStyle|yaml.FoldedStyle) != 0
+		if styled && src.Value == out.Value && out.ShortTag() == "!!str" {
+			out.Style = src.Style
+		}
+	}
+}
+
+// restore writes back the aliases and merge keys whose anchor was restored and whose value still matches it
+func (r *anchorRestorer) restore() {
+	for _, alias := range r.aliases {
+		anchor := r.anchors[alias.name]
+		out := alias.parent.Content[alias.index]
+		if anchor == nil || anchor == out || !sameValue(anchor, out) {
+			continue
+		}
+		alias.parent.Content[alias.index] = &yaml.Node{Kind: yaml.AliasNode, Value: alias.name, Alias: anchor}
+	}
+
+	for _, merge := range r.merges {
+		var aliases []*yaml.Node
+		for _, name := range merge.names {
+			anchor := r.anchors[name]
+			if anchor == nil || anchor.Kind != yaml.MappingNode || !containsPairs(merge.mapping, anchor) {
+				continue
+			}
+			removePairs(merge.mapping, anchor)
+			aliases = append(aliases, &yaml.Node{Kind: yaml.AliasNode, Value: name, Alias: anchor})
+		}
+		if len(aliases) == 0 {
+			continue
+		}
+
+		value := aliases[0]
+		if len(aliases) > 1 {
+			value = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Style: yaml.FlowStyle, Content: aliases}
+		}
+		key := &yaml.Node{Kind: yaml.ScalarNode, Value: "<<"}
+		merge.mapping.Content = append([]*yaml.Node{key, value}, merge.mapping.Content...)
+	}
+}
+
+// expandForwardAliases replaces aliases that would come before their anchor in the output with a copy of
+// the anchored value, defined holds the anchors written so far in document order
+func expandForwardAliases(node *yaml.Node, defined map[string]bool) {
+	if node.Anchor != "" {
+		defined[node.Anchor] = true
+	}
+	for i, child := range node.Content {
+		if child.Kind == yaml.AliasNode && !defined[child.Value] {
+			expanded := *child.Alias
+			expanded.Anchor = ""
+			node.Content[i] = &expanded
+			child = &expanded
+		}
+		expandForwardAliases(child, defined)
+	}
+}
+
+// mergeAliasNames returns the anchor names of a merge key value, a single alias or a list of them
+func mergeAliasNames(value *yaml.Node) []string {
+	if value.Kind == yaml.AliasNode {
+		return []string{value.Value}
+	}
+	var names []string
+	for _, item := range value.Content {
+		if item.Kind == yaml.AliasNode {
+			names = append(names, item.Value)
+		}
+	}
+	return names
+}
+
+// containsPairs reports whether mapping has every key of subset with the same value
+func containsPairs(mapping, subset *yaml.Node) bool {
+	for i := 0; i+1 < len(subset.Content); i += 2 {
+		j := mappingIndex(mapping, subset.Content[i].Value)
+		if j < 0 || !sameValue(subset.Content[i+1], mapping.Content[j+1]) {
+			return false
+		}
+	}
+	return true
+}
+
+// removePairs deletes the keys of subset from mapping
+func removePairs(mapping, subset *yaml.Node) {
+	for i := 0; i+1 < len(subset.Content); i += 2 {
+		if j := mappingIndex(mapping, subset.Content[i].Value); j >= 0 {
+			mapping.Content = append(mapping.Content[:j], mapping.Content[j+2:]...)
+		}
+	}
+}
+
+// mappingIndex returns the index of key's node in a mapping's content, or -1
+func mappingIndex(mapping *yaml.Node, key string) int {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// sameValue reports whether two nodes decode to the same value, whatever their style
+func sameValue(a, b *yaml.Node) bool {
+	var aValue, bValue interface{}
+	if a.Decode(&aValue) != nil || b.Decode(&bValue) != nil {
+		return false
+	}
+	return reflect.DeepEqual(aValue, bValue)
+}
+
+// fileComment returns the comment at the top of a source file. Unless a blank line separates it from the first key,
+// yaml.v3 attaches it to that key, it is taken off the key so it stays at the top instead of moving with the key.
+func fileComment(sourceRoot *yaml.Node) string {
+	var comments []string
+	if sourceRoot.HeadComment != "" {
+		comments = append(comments, sourceRoot.HeadComment)
+	}
+	if mapping := sourceRoot.Content[0]; mapping.Kind == yaml.MappingNode && len(mapping.Content) > 0 {
+		if first := mapping.Content[0]; first.HeadComment != "" {
+			comments = append(comments, first.HeadComment)
+			first.HeadComment = ""
+		}
+	}
+	return strings.Join(comments, "\n\n")
+}
+
+// copyComments gives out the comments of src it does not already have
+func copyComments(src, out *yaml.Node) {
+	if out.HeadComment == "" {
+		out.HeadComment = src.HeadComment
+	}
+	if out.LineComment == "" {
+		out.LineComment = src.LineComment
+	}
+	if out.FootComment == "" {
+		out.FootComment = src.FootComment
+	}
+}
+
+// displayPath shows a source file relative to the project path where possible
+func (dc *DockerComposeCompiler) displayPath(p string) string {
+	rel, err := filepath.Rel(dc.Config.ProjectPath, p)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return p
+	}
+	return filepath.ToSlash(rel)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+)
+
+// pluginVersion is written into the provenance header, set at build time with
+// -ldflags "-X main.pluginVersion=v1.2.3"
+var pluginVersion = "dev"
+
+// contentHashPrefix starts the header line holding the hash of the whole file apart from that line
+const contentHashPrefix = "# Content: sha256:"
+
+// provenanceHeader returns the comment block written above the merged compose: a do not edit warning,
+// the plugin version, every source file with its hash and the content hash, used to detect hand edits.
+// The content hash covers the rest of the header as well as body, so edits anywhere in the file are caught.
+// It ends with a blank line separating it from body.
+func (dc *DockerComposeCompiler) provenanceHeader(body []byte) (string, error) {
+	var header strings.Builder
+	fmt.Fprintf(&header, "# Code generated by odm-plugin-docker-compose %s. DO NOT EDIT.\n", pluginVersion)
+	header.WriteString("# Changes made here are lost on the next merge, edit the source files below instead.\n")
+	header.WriteString("#\n# Sources:\n")
+	for _, source := range dc.Sources {
+		data, err := os.ReadFile(source)
+		if err != nil {
+			return "", fmt.Errorf("error hashing source file:\n\tpath:%s\n\terror:%w", source, err)
+		}
+		fmt.Fprintf(&header, "#   %s sha256:%s\n", dc.displayPath(source), sha256Hex(data))
+	}
+	withoutHash := header.String() + "\n" + string(body)
+	fmt.Fprintf(&header, "%s%s\n\n", contentHashPrefix, sha256Hex([]byte(withoutHash)))
+
+	return header.String(), nil
+}
+
+// checkUnmodified returns an error if the file at path no longer matches the content hash in its
+// provenance header, or has no header at all. A missing file is fine, there is nothing to lose.
+func checkUnmodified(path string) error {
+	current, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error reading existing output file: %w", err)
+	}
+
+	recorded, rest, found := splitContentHash(current)
+	if !found {
+		return fmt.Errorf("refusing to overwrite %s: it has no generated file header, it may have been written by hand", path)
+	}
+	if recorded != sha256Hex(rest) {
+		return fmt.Errorf("refusing to overwrite %s: it was edited by hand since it was generated, move the changes into the source files or remove it", path)
+	}
+	return nil
+}
+
+// splitContentHash finds the content hash recorded in a generated file's header and returns it with the
+// file minus the line holding it, which is what the hash was taken over
+func splitContentHash(data []byte) (hash string, rest []byte, found bool) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	offset := 0
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "#") {
+			break
+		}
+		end := min(offset+len(line)+1, len(data))
+		if strings.HasPrefix(line, contentHashPrefix) {
+			hash = strings.TrimPrefix(line, contentHashPrefix)
+			return hash, slices.Concat(data[:offset], data[end:]), true
+		}
+		offset = end
+	}
+
+	return "", nil, false
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
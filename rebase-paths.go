@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// PathStyle values for Options.PathStyle
+const (
+	// PathStyleRelative writes rebased paths relative to the output file's directory
+	PathStyleRelative = "relative"
+	// PathStyleAbsolute writes rebased paths as absolute paths
+	PathStyleAbsolute = "absolute"
+)
+
+// readSourceFile reads one of the compose files being merged (base, project or override)
+// and rewrites its relative paths so they still resolve from the output file's directory.
+// Services using extends are replaced by the merge of the service they extend and their own fields,
+// and included files are merged in.
+func (dc *DockerComposeCompiler) readSourceFile(filePath string) (*DockerCompose, error) {
+	return dc.readSourceFileFrom(filePath, filepath.Dir(filePath), nil)
+}
+
+// readSourceFileFrom reads a source file whose relative paths resolve against sourceDir,
+// including holds the files that included this one
+func (dc *DockerComposeCompiler) readSourceFileFrom(filePath, sourceDir string, including []string) (*DockerCompose, error) {
+	compose, err := dc.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	dc.addSource(filePath)
+	includes := compose.Include
+	compose.Include = nil
+
+	dc.rebaseCompose(compose, sourceDir)
+
+	err = dc.resolveExtends(compose, filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return dc.resolveIncludes(compose, includes, filePath, including)
+}
+
+// rebaseCompose rewrites every relative path in compose, written relative to sourceDir:
+// build contexts, env files, bind mount sources, extends files and config files. A build without a context
+// builds from sourceDir, so it is given that context to keep building from there. Secret files are moved to the secrets directory, where the secrets are staged for the output.
+func (dc *DockerComposeCompiler) rebaseCompose(compose *DockerCompose, sourceDir string) {
+	for name, service := range compose.Services {
+		if service.Build != nil {
+			if service.Build.Context == "" {
+				service.Build.Context = dc.formatPath(sourceDir)
+			} else {
+				service.Build.Context = dc.rebasePath(service.Build.Context, sourceDir)
+			}
+			service.Build.Dockerfile = dc.rebaseDockerfile(service.Build.Dockerfile, service.Build.Context)
+		}
+		for i, envFile := range service.EnvFile {
+			service.EnvFile[i].Path = dc.rebasePath(envFile.Path, sourceDir)
+		}
+		for i, volume := range service.Volumes {
+			if volume.Type == "bind" {
+				service.Volumes[i].setSource(dc.rebasePath(volume.Source, sourceDir))
+			}
+		}
+		if service.Extends != nil {
+			service.Extends.File = dc.rebasePath(service.Extends.File, sourceDir)
+		}
+		compose.Services[name] = service
+	}
+
+	for name, secret := range compose.Secrets {
+		if secret.File != "" {
+			secret.File = dc.formatPath(filepath.Join(dc.SecretsDir, filepath.Base(secret.File)))
+		}
+		compose.Secrets[name] = secret
+	}
+	for name, config := range compose.Configs {
+		config.File = dc.rebasePath(config.File, sourceDir)
+		compose.Configs[name] = config
+	}
+}
+
+// rebasePath resolves a relative path against sourceDir and re-expresses it for the output file.
+// Absolute paths, home relative paths, paths starting with a variable and URLs are left alone.
+func (dc *DockerComposeCompiler) rebasePath(p, sourceDir string) string {
+	if p == "" || filepath.IsAbs(p) || strings.HasPrefix(p, "~") || strings.HasPrefix(p, "$") || isRemotePath(p) {
+		return p
+	}
+
+	return dc.formatPath(filepath.Join(sourceDir, p))
+}
+
+// rebaseDockerfile handles build.dockerfile, which compose resolves against the build context rather than
+// the compose file. It only needs rewriting when paths are written absolute.
+func (dc *DockerComposeCompiler) rebaseDockerfile(dockerfile, context string) string {
+	if dc.Config.PathStyle != PathStyleAbsolute || dockerfile == "" || filepath.IsAbs(dockerfile) || isRemotePath(context) {
+		return dockerfile
+	}
+	return filepath.Join(dc.outputRelativePath(context), dockerfile)
+}
+
+// formatPath writes an absolute path relative to the output file's directory, or unchanged for PathStyleAbsolute
+func (dc *DockerComposeCompiler) formatPath(abs string) string {
+	if dc.Config.PathStyle == PathStyleAbsolute {
+		return abs
+	}
+
+	rel, err := filepath.Rel(filepath.Dir(dc.OutputPath), abs)
+	if err != nil {
+		return abs
+	}
+	if rel != "." && !strings.HasPrefix(rel, "..") {
+		rel = "./" + rel
+	}
+	return rel
+}
+
+// isRemotePath reports whether p is a URL or git remote rather than a local path, e.g. a git build context
+func isRemotePath(p string) bool {
+	return strings.Contains(p, "://") || strings.HasPrefix(p, "git@") || strings.HasPrefix(p, "github.com/")
+}
+
+// outputRelativePath turns a path written for the output file back into one usable from the plugin
+func (dc *DockerComposeCompiler) outputRelativePath(p string) string {
+	if filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(filepath.Dir(dc.OutputPath), p)
+}
+
+// inlineEnvFiles reads every service's env_file into its environment and drops the env_file entries.
+// As in compose, values set in environment take precedence over those from env files, and bare KEY lines
+// become pass-through variables.
+func (dc *DockerComposeCompiler) inlineEnvFiles(compose *DockerCompose) error {
+	for _, name := range slices.Sorted(maps.Keys(compose.Services)) {
+		service := compose.Services[name]
+		if len(service.EnvFile) == 0 {
+			continue
+		}
+
+		fromFiles := Environment{}
+		for _, envFile := range service.EnvFile {
+			envPath := dc.outputRelativePath(envFile.Path)
+			variables, err := readEnvFile(envPath)
+			if err != nil {
+				if !envFile.IsRequired() {
+					dc.warn("service %s: skipping optional env_file %s: %s", name, envPath, err)
+					continue
+				}
+				return fmt.Errorf("service %s: error reading env_file:\n\tpath:%s\n\terror:%w", name, envPath, err)
+			}
+			maps.Copy(fromFiles, variables)
+		}
+
+		service.Environment = fromFiles.Merge(service.Environment)
+		service.EnvFile = nil
+		compose.Services[name] = service
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// extendsResolver resolves the extends sections of one source file, reading the other files it
+// refers to once and remembering each service it has resolved
+type extendsResolver struct {
+	dc       *DockerComposeCompiler
+	files    map[string]*DockerCompose // compose files by cleaned path, already rebased
+	resolved map[string]Service        // resolved services by "path#service"
+}
+
+// resolveExtends replaces every service in compose that uses extends, compose was read from filePath.
+// A service may extend one in the same file or in another file, which may itself use extends.
+func (dc *DockerComposeCompiler) resolveExtends(compose *DockerCompose, filePath string) error {
+	filePath = filepath.Clean(filePath)
+	r := &extendsResolver{
+		dc:       dc,
+		files:    map[string]*DockerCompose{filePath: compose},
+		resolved: map[string]Service{},
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(compose.Services)) {
+		if compose.Services[name].Extends == nil {
+			continue
+		}
+		service, err := r.resolve(filePath, name, nil)
+		if err != nil {
+			return fmt.Errorf("service %s: %w", name, err)
+		}
+		compose.Services[name] = service
+	}
+
+	return nil
+}
+
+// resolve returns the service name from filePath with its extends applied, chain holds the
+// services being resolved further up, to detect cycles
+func (r *extendsResolver) resolve(filePath, name string, chain []string) (Service, error) {
+	key := filePath + "#" + name
+	if service, done := r.resolved[key]; done {
+		return service, nil
+	}
+	if slices.Contains(chain, key) {
+		return Service{}, fmt.Errorf("extends cycle: %s", strings.Join(append(chain, key), " -> "))
+	}
+	chain = append(chain, key)
+
+	compose, err := r.file(filePath)
+	if err != nil {
+		return Service{}, err
+	}
+	service, exists := compose.Services[name]
+	if !exists {
+		return Service{}, fmt.Errorf("extended service %s not found in %s", name, filePath)
+	}
+	if service.Extends == nil {
+		r.resolved[key] = service
+		return service, nil
+	}
+
+	baseFile := filePath
+	if service.Extends.File != "" {
+		baseFile = filepath.Clean(r.dc.outputRelativePath(service.Extends.File))
+	}
+	base, err := r.resolve(baseFile, service.Extends.Service, chain)
+	if err != nil {
+		return Service{}, err
+	}
+	if baseFile != filePath && base.Build != nil && base.Build.Context == "" {
+		// Like its other paths, the default build context of a service from another file is that file's directory
+		build := *base.Build
+		build.Context = r.dc.formatPath(filepath.Dir(baseFile))
+		base.Build = &build
+	}
+
+	service.Extends = nil
+	merged := r.dc.mergeServices(base, service)
+	merged.Extends = nil
+	r.resolved[key] = merged
+	return merged, nil
+}
+
+// file returns the compose file at filePath, reading and rebasing it the first time it is needed
+func (r *extendsResolver) file(filePath string) (*DockerCompose, error) {
+	if compose, read := r.files[filePath]; read {
+		return compose, nil
+	}
+
+	fmt.Println("Reading extended docker-compose:", filePath)
+	compose, err := r.dc.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading extended file:\n\tpath:%s\n\terror:%w", filePath, err)
+	}
+	r.dc.addSource(filePath)
+	r.dc.rebaseCompose(compose, filepath.Dir(filePath))
+
+	r.files[filePath] = compose
+	return compose, nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// resolveIncludes merges each included model into compose, which was read from filePath.
+// As in compose, a resource may not be defined both by a file and by what it includes. Conflicts are
+// reported and the including file's definition kept, or fail the build in strict mode.
+func (dc *DockerComposeCompiler) resolveIncludes(compose *DockerCompose, includes []IncludeConfig, filePath string, including []string) (*DockerCompose, error) {
+	including = append(including, filepath.Clean(filePath))
+
+	for _, include := range includes {
+		included, err := dc.readInclude(include, filepath.Dir(filePath), including)
+		if err != nil {
+			return nil, err
+		}
+
+		var conflicts []string
+		opts := &MergeOptions{
+			MergeServices: false,
+			OnConflict: func(itemType, name string, first, second interface{}) bool {
+				conflicts = append(conflicts, fmt.Sprintf("%s %s", itemType, name))
+				if dc.Result != nil {
+					dc.Result.Conflicts = append(dc.Result.Conflicts, ConflictResolution{
+						Type:       itemType,
+						Name:       name,
+						Resolution: "second",
+					})
+				}
+				return true
+			},
+		}
+		compose, err = dc.combineDockerCompose(included, compose, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(conflicts) > 0 {
+			msg := fmt.Sprintf("%s redefines %s from included %s", filePath, strings.Join(conflicts, ", "), strings.Join(include.Path, ", "))
+			if dc.Config.Strictness == StrictnessStrict {
+				return nil, fmt.Errorf("%s", msg)
+			}
+			dc.warn("%s, keeping the including file's definitions", msg)
+		}
+	}
+
+	return compose, nil
+}
+
+// readInclude reads the files of one include entry, relative paths resolving against dir, and merges them in order
+func (dc *DockerComposeCompiler) readInclude(include IncludeConfig, dir string, including []string) (*DockerCompose, error) {
+	if len(include.Path) == 0 {
+		return nil, fmt.Errorf("include entry without a path in %s", including[len(including)-1])
+	}
+
+	paths := make([]string, len(include.Path))
+	for i, p := range include.Path {
+		paths[i] = joinPath(dir, p)
+		if slices.Contains(including, paths[i]) {
+			return nil, fmt.Errorf("include cycle: %s", strings.Join(append(including, paths[i]), " -> "))
+		}
+	}
+
+	projectDir := filepath.Dir(paths[0])
+	if include.ProjectDirectory != "" {
+		projectDir = joinPath(dir, include.ProjectDirectory)
+	}
+
+	if dc.Config.Interpolate && len(include.EnvFile) > 0 {
+		// The env files only apply to the included files, values already set take precedence
+		variables := dc.Variables
+		defer func() { dc.Variables = variables }()
+
+		dc.Variables = map[string]string{}
+		for _, f := range include.EnvFile {
+			envPath := joinPath(dir, f)
+			fileVars, err := readEnvVariables(envPath)
+			if err != nil {
+				return nil, fmt.Errorf("error reading include env file:\n\tpath:%s\n\terror:%w", envPath, err)
+			}
+			maps.Copy(dc.Variables, fileVars)
+		}
+		maps.Copy(dc.Variables, variables)
+	}
+
+	mergeOpts := &MergeOptions{
+		MergeServices: true,
+		OnConflict:    dc.DefaultOnConflict,
+	}
+
+	var merged *DockerCompose
+	for _, p := range paths {
+		fmt.Println("Reading included docker-compose:", p)
+		compose, err := dc.readSourceFileFrom(p, projectDir, including)
+		if err != nil {
+			return nil, fmt.Errorf("error reading included yml:\n\tpath:%s\n\terror:%w", p, err)
+		}
+		merged, err = dc.combineDockerCompose(merged, compose, mergeOpts)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return merged, nil
+}
+
+// joinPath resolves p against dir unless it is absolute
+func joinPath(dir, p string) string {
+	if filepath.IsAbs(p) {
+		return filepath.Clean(p)
+	}
+	return filepath.Join(dir, p)
+}